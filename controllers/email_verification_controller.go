@@ -0,0 +1,165 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Neph-dev/MovieStreamServer/mailer"
+	"github.com/Neph-dev/MovieStreamServer/models"
+	"github.com/Neph-dev/MovieStreamServer/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+func emailVerificationTTL() time.Duration {
+	return time.Duration(utils.GetEnvInt("EMAIL_VERIFICATION_TTL_HOURS", 48)) * time.Hour
+}
+
+// MigrateEmailVerification marks accounts created before email verification
+// as verified, so turning it on does not lock existing users out.
+func MigrateEmailVerification() {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	_, err := userCollection.UpdateMany(
+		ctx,
+		bson.M{"email_verified": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"email_verified": true}},
+	)
+	if err != nil {
+		log.Println("Error migrating email verification:", err)
+	}
+}
+
+func sendVerificationEmail(ctx context.Context, user models.User) error {
+	token, err := utils.SignEmailVerificationToken(user.UserID, user.Email, emailVerificationTTL())
+	if err != nil {
+		return err
+	}
+
+	return sendMail(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your MovieStream email",
+		Body: "Hi " + user.FirstName + ",\n\n" +
+			"Welcome to MovieStream. To verify your email address, open:\n\n" +
+			tokenInstructions("/verify-email", token) + "\n" +
+			"This expires in " + emailVerificationTTL().String() + ". If you did not sign up, ignore this email.\n",
+	})
+}
+
+// VerifyEmail marks the address in a verification token as verified. Tokens
+// for an address the account no longer has are rejected.
+func VerifyEmail() gin.HandlerFunc {
+	return func(_context *gin.Context) {
+		var request models.EmailVerificationRequest
+		if err := _context.BindJSON(&request); err != nil {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+
+		var validate = validator.New()
+		if err := validate.Struct(request); err != nil {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "details": err.Error()})
+			return
+		}
+
+		claims, err := utils.ValidateEmailVerificationToken(request.Token)
+		if err != nil {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": "Verification token is invalid or has expired"})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var user models.User
+		err = userCollection.FindOne(ctx, utils.ActiveFilter(bson.M{"user_id": claims.UID, "email": claims.Email})).Decode(&user)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": "Verification token is invalid or has expired"})
+			return
+		}
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching user"})
+			return
+		}
+
+		if user.EmailVerified {
+			_context.JSON(http.StatusOK, gin.H{"message": "Email already verified"})
+			return
+		}
+
+		_, err = userCollection.UpdateOne(
+			ctx,
+			bson.M{"user_id": user.UserID, "email": claims.Email},
+			bson.M{"$set": bson.M{"email_verified": true, "email_verified_at": time.Now(), "updated_at": time.Now()}},
+		)
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error verifying email"})
+			return
+		}
+
+		_context.JSON(http.StatusOK, gin.H{"message": "Email verified successfully"})
+	}
+}
+
+// ResendVerificationEmail sends a new verification link. It is unprotected so
+// users who cannot log in yet can use it, and answers the same whether or not
+// an unverified account exists. Each address can ask once per cooldown.
+func ResendVerificationEmail() gin.HandlerFunc {
+	return func(_context *gin.Context) {
+		var request models.ResendVerificationRequest
+		if err := _context.BindJSON(&request); err != nil {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+
+		var validate = validator.New()
+		if err := validate.Struct(request); err != nil {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "details": err.Error()})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		cooldown := time.Duration(utils.GetEnvInt("EMAIL_VERIFICATION_RESEND_COOLDOWN_SECONDS", 60)) * time.Second
+
+		allowed, err := utils.AllowRate(ctx, "email_verification:email:"+strings.ToLower(request.Email), 1, cooldown)
+		if err == nil && allowed {
+			allowed, err = utils.AllowRate(ctx, "email_verification:ip:"+_context.ClientIP(), utils.GetEnvInt("EMAIL_VERIFICATION_IP_LIMIT", 20), time.Hour)
+		}
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking rate limit"})
+			return
+		}
+		if !allowed {
+			_context.JSON(http.StatusTooManyRequests, gin.H{"error": "A verification email was sent recently, try again later"})
+			return
+		}
+
+		go func(email string) {
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+			defer cancel()
+
+			var user models.User
+			err := userCollection.FindOne(ctx, utils.ActiveFilter(bson.M{"email": email, "email_verified": false})).Decode(&user)
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				return
+			}
+			if err == nil {
+				err = sendVerificationEmail(ctx, user)
+			}
+			if err != nil {
+				log.Println("Error resending verification email:", err)
+			}
+		}(request.Email)
+
+		_context.JSON(http.StatusAccepted, gin.H{"message": "If an unverified account exists for this email, a verification link has been sent"})
+	}
+}
@@ -0,0 +1,210 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Neph-dev/MovieStreamServer/models"
+	"github.com/Neph-dev/MovieStreamServer/utils"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+type exportSource struct {
+	collection *mongo.Collection
+	header     []string
+	projection bson.M
+	decode     func(*mongo.Cursor) (interface{}, error)
+	toCSV      func(interface{}) ([]string, error)
+}
+
+var exportSources = map[string]exportSource{
+	"movies": {
+		collection: movieCollection,
+		header:     utils.MovieExportCSVHeader,
+		decode: func(cursor *mongo.Cursor) (interface{}, error) {
+			var movie models.Movie
+			err := cursor.Decode(&movie)
+			return movie, err
+		},
+		toCSV: func(document interface{}) ([]string, error) {
+			return utils.MovieToCSVRecord(document.(models.Movie))
+		},
+	},
+	"rankings": {
+		collection: rankingCollection,
+		header:     []string{"ranking_value", "ranking_name", "is_default", "order"},
+		decode: func(cursor *mongo.Cursor) (interface{}, error) {
+			var ranking models.RankingDefinition
+			err := cursor.Decode(&ranking)
+			return ranking, err
+		},
+		toCSV: func(document interface{}) ([]string, error) {
+			ranking := document.(models.RankingDefinition)
+			return []string{
+				strconv.Itoa(ranking.RankingValue),
+				ranking.RankingName,
+				strconv.FormatBool(ranking.IsDefault),
+				strconv.Itoa(ranking.Order),
+			}, nil
+		},
+	},
+	"users": {
+		collection: userCollection,
+		header:     []string{"user_id", "first_name", "last_name", "email", "role", "created_at", "updated_at", "deleted_at"},
+		// An allow-list, so password hashes, tokens and fields added later
+		// never leak into a backup by default.
+		projection: bson.M{
+			"_id": 0, "user_id": 1, "first_name": 1, "last_name": 1, "email": 1, "email_verified": 1,
+			"role": 1, "favourite_genres": 1, "created_at": 1, "updated_at": 1, "deleted_at": 1,
+		},
+		decode: func(cursor *mongo.Cursor) (interface{}, error) {
+			var user models.User
+			err := cursor.Decode(&user)
+			return toUserResponse(user), err
+		},
+		toCSV: func(document interface{}) ([]string, error) {
+			user := document.(models.UserResponse)
+			deletedAt := ""
+			if user.DeletedAt != nil {
+				deletedAt = user.DeletedAt.Format(time.RFC3339)
+			}
+			return []string{
+				user.UserID,
+				user.FirstName,
+				user.LastName,
+				user.Email,
+				user.Role,
+				user.CreatedAt.Format(time.RFC3339),
+				user.UpdatedAt.Format(time.RFC3339),
+				deletedAt,
+			}, nil
+		},
+	},
+}
+
+// ExportCollection streams every document of kind that matches filter to out.
+// It is shared by the admin export endpoint and the `export` subcommand. A
+// failure part-way is written to out as a final error record.
+//
+// Movie exports can be imported again through POST /movies/import, which
+// reads the catalog fields, admin review and ranking only. version,
+// ranking_status, ranking_source, user_rating and the trash fields are kept
+// by the database itself and are exported for reference, not restored; a
+// changed review is ranked again. Rankings and users have no import.
+func ExportCollection(ctx context.Context, out io.Writer, kind string, format string, filter bson.M) (int, error) {
+	source, ok := exportSources[kind]
+	if !ok {
+		return 0, errors.New("unknown export kind " + kind)
+	}
+
+	writer, err := utils.NewExportWriter(out, format, source.header, source.toCSV)
+	if err != nil {
+		return 0, err
+	}
+
+	count, err := writeExport(ctx, out, writer, source, filter)
+	if err != nil {
+		if failErr := writer.Fail(err); failErr != nil {
+			log.Printf("Error recording failed export of %s: %v", kind, failErr)
+		}
+	}
+
+	return count, err
+}
+
+func writeExport(ctx context.Context, out io.Writer, writer *utils.ExportWriter, source exportSource, filter bson.M) (int, error) {
+	findOptions := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}})
+	if source.projection != nil {
+		findOptions.SetProjection(source.projection)
+	}
+
+	cursor, err := source.collection.Find(ctx, filter, findOptions)
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		document, err := source.decode(cursor)
+		if err != nil {
+			return writer.Count(), err
+		}
+
+		if err := writer.Write(document); err != nil {
+			return writer.Count(), err
+		}
+
+		if writer.Count()%500 == 0 {
+			if err := writer.Flush(); err != nil {
+				return writer.Count(), err
+			}
+			if flusher, ok := out.(http.Flusher); ok {
+				flusher.Flush()
+			}
+		}
+	}
+
+	if err := cursor.Err(); err != nil {
+		return writer.Count(), err
+	}
+
+	return writer.Count(), writer.Close()
+}
+
+func ExportData() gin.HandlerFunc {
+	return func(_context *gin.Context) {
+		kind := _context.Param("kind")
+		if kind != "movies" && kind != "rankings" {
+			_context.JSON(http.StatusNotFound, gin.H{"error": "Unknown export kind, expected movies or rankings"})
+			return
+		}
+
+		format := _context.DefaultQuery("format", "ndjson")
+		contentType, ok := utils.ExportContentTypes[format]
+		if !ok {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": "format must be ndjson, csv or json"})
+			return
+		}
+
+		backup, err := strconv.ParseBool(_context.DefaultQuery("backup", "false"))
+		if err != nil {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": "backup must be true or false"})
+			return
+		}
+
+		filter := bson.M{}
+		if kind == "movies" {
+			filter, err = BuildMovieFilter(_context)
+			if err != nil {
+				_context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			// A backup also keeps the movies that are in the trash.
+			if backup {
+				delete(filter, "deleted_at")
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+		defer cancel()
+
+		fileName := kind + "-" + time.Now().Format("20060102-150405") + "." + format
+		_context.Header("Content-Type", contentType)
+		_context.Header("Content-Disposition", `attachment; filename="`+fileName+`"`)
+		_context.Status(http.StatusOK)
+
+		// Headers are already sent, so a failure part-way is logged and ends
+		// the file with an error record.
+		if count, err := ExportCollection(ctx, _context.Writer, kind, format, filter); err != nil {
+			log.Printf("Export of %s failed after %d documents: %v", kind, count, err)
+		}
+	}
+}
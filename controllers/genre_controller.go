@@ -0,0 +1,383 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	db "github.com/Neph-dev/MovieStreamServer/database"
+	"github.com/Neph-dev/MovieStreamServer/models"
+	"github.com/Neph-dev/MovieStreamServer/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+var genreCollection *mongo.Collection = db.OpenCollection("genres")
+
+var genreIndexesOnce sync.Once
+
+// Genre names are unique regardless of case, so "Sci-Fi" and "sci-fi" cannot
+// both exist.
+var genreNameCollation = &options.Collation{Locale: "en", Strength: 2}
+
+type unknownGenresError struct {
+	ids []int
+}
+
+func (err *unknownGenresError) Error() string {
+	ids := make([]string, len(err.ids))
+	for i, id := range err.ids {
+		ids[i] = strconv.Itoa(id)
+	}
+
+	return "unknown genre_id: " + strings.Join(ids, ", ")
+}
+
+func ensureGenreIndexes(ctx context.Context) {
+	genreIndexesOnce.Do(func() {
+		_, err := genreCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+			{Keys: bson.D{{Key: "genre_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+			{Keys: bson.D{{Key: "genre_name", Value: 1}}, Options: options.Index().SetUnique(true).SetCollation(genreNameCollation)},
+		})
+		if err != nil {
+			log.Println("Warning: could not create genres indexes:", err)
+		}
+	})
+}
+
+// MigrateGenres fills the genres collection with the genres already used by
+// movies and users' favourites, so that ResolveGenres accepts them. Existing
+// genres are left as they are; when data uses one genre_id under several
+// names, the first one found is kept.
+func MigrateGenres() {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	ensureGenreIndexes(ctx)
+
+	sources := []struct {
+		collection *mongo.Collection
+		field      string
+	}{
+		{movieCollection, "genre"},
+		{userCollection, "favourite_genres"},
+	}
+
+	added := 0
+	for _, source := range sources {
+		pipeline := mongo.Pipeline{
+			{{Key: "$unwind", Value: "$" + source.field}},
+			{{Key: "$match", Value: bson.M{source.field + ".genre_name": bson.M{"$nin": bson.A{"", nil}}}}},
+			{{Key: "$group", Value: bson.M{
+				"_id":        "$" + source.field + ".genre_id",
+				"genre_name": bson.M{"$first": "$" + source.field + ".genre_name"},
+			}}},
+			{{Key: "$sort", Value: bson.M{"_id": 1}}},
+		}
+
+		cursor, err := source.collection.Aggregate(ctx, pipeline)
+		if err != nil {
+			log.Printf("Error migrating genres from %s: %v", source.field, err)
+			continue
+		}
+
+		var found []struct {
+			GenreID   int    `bson:"_id"`
+			GenreName string `bson:"genre_name"`
+		}
+		err = cursor.All(ctx, &found)
+		cursor.Close(ctx)
+		if err != nil {
+			log.Printf("Error migrating genres from %s: %v", source.field, err)
+			continue
+		}
+
+		for _, genre := range found {
+			result, err := genreCollection.UpdateOne(
+				ctx,
+				bson.M{"genre_id": genre.GenreID},
+				bson.M{"$setOnInsert": bson.M{"genre_name": genre.GenreName}},
+				options.UpdateOne().SetUpsert(true),
+			)
+			if err != nil {
+				// Another id already owns the name.
+				log.Printf("Error migrating genre %d (%s): %v", genre.GenreID, genre.GenreName, err)
+				continue
+			}
+			if result.UpsertedCount > 0 {
+				added++
+			}
+		}
+	}
+
+	if added > 0 {
+		log.Printf("Added %d genres found in movies and users", added)
+	}
+}
+
+// ResolveGenres checks every submitted genre against the genres collection by
+// genre_id and returns them with their canonical names. Unknown ids produce an
+// *unknownGenresError.
+func ResolveGenres(ctx context.Context, genres []models.Genre) ([]models.Genre, error) {
+	if len(genres) == 0 {
+		return genres, nil
+	}
+
+	ids := make([]int, 0, len(genres))
+	for _, genre := range genres {
+		ids = append(ids, genre.GenreID)
+	}
+
+	cursor, err := genreCollection.Find(ctx, bson.M{"genre_id": bson.M{"$in": ids}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var catalog []models.Genre
+	if err = cursor.All(ctx, &catalog); err != nil {
+		return nil, err
+	}
+
+	names := map[int]string{}
+	for _, genre := range catalog {
+		names[genre.GenreID] = genre.GenreName
+	}
+
+	resolved := make([]models.Genre, 0, len(genres))
+	seen := map[int]bool{}
+	var unknown []int
+
+	for _, genre := range genres {
+		name, ok := names[genre.GenreID]
+		if !ok {
+			unknown = append(unknown, genre.GenreID)
+			continue
+		}
+		if seen[genre.GenreID] {
+			continue
+		}
+		seen[genre.GenreID] = true
+		resolved = append(resolved, models.Genre{GenreID: genre.GenreID, GenreName: name})
+	}
+
+	if len(unknown) > 0 {
+		return nil, &unknownGenresError{ids: unknown}
+	}
+
+	return resolved, nil
+}
+
+// respondGenreError writes the response for a ResolveGenres failure.
+func respondGenreError(_context *gin.Context, err error) {
+	var unknown *unknownGenresError
+	if errors.As(err, &unknown) {
+		_context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking genres"})
+}
+
+func GetGenres() gin.HandlerFunc {
+	return func(_context *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		findOptions := options.Find().
+			SetSort(bson.D{{Key: "genre_name", Value: 1}}).
+			SetCollation(genreNameCollation)
+
+		cursor, err := genreCollection.Find(ctx, bson.M{}, findOptions)
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching genres from database"})
+			return
+		}
+		defer cursor.Close(ctx)
+
+		genres := []models.Genre{}
+		if err = cursor.All(ctx, &genres); err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error decoding genres from database"})
+			return
+		}
+
+		_context.JSON(http.StatusOK, genres)
+	}
+}
+
+func CreateGenre() gin.HandlerFunc {
+	return func(_context *gin.Context) {
+		var genre models.Genre
+
+		if err := _context.BindJSON(&genre); err != nil {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+
+		genre.GenreName = strings.TrimSpace(genre.GenreName)
+
+		var validate = validator.New()
+		if err := validate.Struct(genre); err != nil {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		ensureGenreIndexes(ctx)
+
+		if err := utils.InsertDocument(ctx, genreCollection, genre); err != nil {
+			if mongo.IsDuplicateKeyError(err) {
+				_context.JSON(http.StatusConflict, gin.H{"error": "Genre with this id or name already exists"})
+				return
+			}
+
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error inserting genre into database"})
+			return
+		}
+
+		_context.JSON(http.StatusCreated, genre)
+	}
+}
+
+func getGenreIDParam(_context *gin.Context) (int, bool) {
+	genreID, err := strconv.Atoi(_context.Param("genre_id"))
+	if err != nil {
+		_context.JSON(http.StatusBadRequest, gin.H{"error": "genre_id must be an integer"})
+		return 0, false
+	}
+
+	return genreID, true
+}
+
+// RenameGenre changes a genre's name and rewrites every copy embedded in
+// movies and users' favourite genres.
+func RenameGenre() gin.HandlerFunc {
+	return func(_context *gin.Context) {
+		genreID, ok := getGenreIDParam(_context)
+		if !ok {
+			return
+		}
+
+		var rename struct {
+			GenreName string `json:"genre_name" validate:"required,min=2,max=100"`
+		}
+
+		if err := _context.BindJSON(&rename); err != nil {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+
+		rename.GenreName = strings.TrimSpace(rename.GenreName)
+
+		var validate = validator.New()
+		if err := validate.Struct(rename); err != nil {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		ensureGenreIndexes(ctx)
+
+		result, err := genreCollection.UpdateOne(ctx, bson.M{"genre_id": genreID}, bson.M{"$set": bson.M{"genre_name": rename.GenreName}})
+		if err != nil {
+			if mongo.IsDuplicateKeyError(err) {
+				_context.JSON(http.StatusConflict, gin.H{"error": "Genre with this name already exists"})
+				return
+			}
+
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error renaming genre"})
+			return
+		}
+		if result.MatchedCount == 0 {
+			_context.JSON(http.StatusNotFound, gin.H{"error": "Genre not found"})
+			return
+		}
+
+		arrayFilter := []interface{}{bson.M{"g.genre_id": genreID}}
+
+		movies, err := movieCollection.UpdateMany(
+			ctx,
+			bson.M{"genre.genre_id": genreID},
+			bson.M{"$set": bson.M{"genre.$[g].genre_name": rename.GenreName}, "$inc": bson.M{"version": 1}},
+			options.UpdateMany().SetArrayFilters(arrayFilter),
+		)
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Genre renamed but updating movies failed, retry the rename"})
+			return
+		}
+
+		users, err := userCollection.UpdateMany(
+			ctx,
+			bson.M{"favourite_genres.genre_id": genreID},
+			bson.M{"$set": bson.M{"favourite_genres.$[g].genre_name": rename.GenreName}},
+			options.UpdateMany().SetArrayFilters(arrayFilter),
+		)
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Genre renamed but updating users failed, retry the rename"})
+			return
+		}
+
+		_context.JSON(http.StatusOK, gin.H{
+			"message":        "Genre renamed successfully",
+			"genre":          models.Genre{GenreID: genreID, GenreName: rename.GenreName},
+			"movies_updated": movies.ModifiedCount,
+			"users_updated":  users.ModifiedCount,
+		})
+	}
+}
+
+func DeleteGenre() gin.HandlerFunc {
+	return func(_context *gin.Context) {
+		genreID, ok := getGenreIDParam(_context)
+		if !ok {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		inUse, err := utils.DocumentExists(ctx, movieCollection, bson.M{"genre.genre_id": genreID})
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking genre usage"})
+			return
+		}
+		if inUse {
+			_context.JSON(http.StatusConflict, gin.H{"error": "Genre is still used by movies"})
+			return
+		}
+
+		result, err := genreCollection.DeleteOne(ctx, bson.M{"genre_id": genreID})
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error deleting genre"})
+			return
+		}
+		if result.DeletedCount == 0 {
+			_context.JSON(http.StatusNotFound, gin.H{"error": "Genre not found"})
+			return
+		}
+
+		_, err = userCollection.UpdateMany(
+			ctx,
+			bson.M{"favourite_genres.genre_id": genreID},
+			bson.M{"$pull": bson.M{"favourite_genres": bson.M{"genre_id": genreID}}},
+		)
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Genre deleted but removing it from users failed"})
+			return
+		}
+
+		_context.JSON(http.StatusOK, gin.H{"message": "Genre deleted successfully"})
+	}
+}
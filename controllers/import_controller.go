@@ -0,0 +1,633 @@
+package controllers
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	db "github.com/Neph-dev/MovieStreamServer/database"
+	"github.com/Neph-dev/MovieStreamServer/models"
+	"github.com/Neph-dev/MovieStreamServer/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+var importJobCollection *mongo.Collection = db.OpenCollection("import_jobs")
+var importRowCollection *mongo.Collection = db.OpenCollection("import_job_rows")
+
+// importSlots bounds how many imports are processed at the same time.
+var importSlots = make(chan struct{}, 2)
+
+const importRowBatchSize = 500
+
+func importMaxUploadBytes() int64 {
+	var megabytes int64 = 512
+
+	if value := os.Getenv("IMPORT_MAX_UPLOAD_MB"); value != "" {
+		if parsed, err := strconv.ParseInt(value, 10, 64); err == nil && parsed > 0 {
+			megabytes = parsed
+		}
+	}
+
+	return megabytes << 20
+}
+
+func detectImportFormat(format string, contentType string, fileName string) (string, error) {
+	switch strings.ToLower(format) {
+	case "csv":
+		return "csv", nil
+	case "ndjson", "jsonl":
+		return "ndjson", nil
+	case "json":
+		return "json", nil
+	case "":
+	default:
+		return "", errors.New("format must be csv, ndjson or json")
+	}
+
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		switch mediaType {
+		case "text/csv":
+			return "csv", nil
+		case "application/x-ndjson", "application/ndjson", "application/jsonl", "application/x-jsonlines":
+			return "ndjson", nil
+		case "application/json":
+			return "json", nil
+		}
+	}
+
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".csv":
+		return "csv", nil
+	case ".ndjson", ".jsonl":
+		return "ndjson", nil
+	case ".json":
+		return "json", nil
+	}
+
+	return "", errors.New("could not detect upload format, pass format=csv, format=ndjson or format=json")
+}
+
+// openImportUpload returns the uploaded file as a stream, either from the
+// "file" part of a multipart form or from the raw request body.
+func openImportUpload(_context *gin.Context) (io.Reader, string, string, error) {
+	contentType := _context.GetHeader("Content-Type")
+
+	if mediaType, _, _ := mime.ParseMediaType(contentType); mediaType != "multipart/form-data" {
+		return _context.Request.Body, "", contentType, nil
+	}
+
+	reader, err := _context.Request.MultipartReader()
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	for {
+		part, err := reader.NextPart()
+		if err != nil {
+			return nil, "", "", errors.New("multipart upload has no file part")
+		}
+
+		if part.FormName() == "file" {
+			return part, part.FileName(), part.Header.Get("Content-Type"), nil
+		}
+	}
+}
+
+func ImportMovies() gin.HandlerFunc {
+	return func(_context *gin.Context) {
+		userId, err := utils.GetDataFromContext(_context, "userId")
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving user data from context"})
+			return
+		}
+
+		mode := _context.DefaultQuery("mode", models.ImportModeInsert)
+		if mode != models.ImportModeInsert && mode != models.ImportModeUpsert {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": "mode must be insert or upsert"})
+			return
+		}
+
+		dryRun, err := strconv.ParseBool(_context.DefaultQuery("dry_run", "false"))
+		if err != nil {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": "dry_run must be true or false"})
+			return
+		}
+
+		_context.Request.Body = http.MaxBytesReader(_context.Writer, _context.Request.Body, importMaxUploadBytes())
+
+		upload, fileName, contentType, err := openImportUpload(_context)
+		if err != nil {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		format, err := detectImportFormat(_context.Query("format"), contentType, fileName)
+		if err != nil {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// The upload is streamed to disk rather than memory so that the job
+		// can keep reading it after this request has returned.
+		spool, err := os.CreateTemp("", "movie-import-*")
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error storing upload"})
+			return
+		}
+
+		if _, err := io.Copy(spool, upload); err != nil {
+			spool.Close()
+			os.Remove(spool.Name())
+			_context.JSON(http.StatusBadRequest, gin.H{"error": "Error reading upload: " + err.Error()})
+			return
+		}
+		spool.Close()
+
+		job := models.ImportJob{
+			JobID:     bson.NewObjectID().Hex(),
+			Status:    models.JobStatusQueued,
+			Mode:      mode,
+			DryRun:    dryRun,
+			Format:    format,
+			FileName:  fileName,
+			CreatedBy: userId,
+			CreatedAt: time.Now(),
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		if err := utils.InsertDocument(ctx, importJobCollection, job); err != nil {
+			os.Remove(spool.Name())
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating import job"})
+			return
+		}
+
+		go runImportJob(job, spool.Name())
+
+		_context.JSON(http.StatusAccepted, gin.H{
+			"message":    "Import job queued",
+			"job_id":     job.JobID,
+			"status":     job.Status,
+			"status_url": "/movies/import/" + job.JobID,
+		})
+	}
+}
+
+func GetImportJob() gin.HandlerFunc {
+	return func(_context *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var job models.ImportJob
+		err := importJobCollection.FindOne(ctx, bson.M{"job_id": _context.Param("job_id")}).Decode(&job)
+		if err != nil {
+			_context.JSON(http.StatusNotFound, gin.H{"error": "Import job not found"})
+			return
+		}
+
+		_context.JSON(http.StatusOK, job)
+	}
+}
+
+func GetImportJobRows() gin.HandlerFunc {
+	return func(_context *gin.Context) {
+		limit, err := utils.GetLimitParam(_context, 100, 1000)
+		if err != nil {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		page, err := strconv.ParseInt(_context.DefaultQuery("page", "1"), 10, 64)
+		if err != nil || page < 1 {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": "page must be a positive integer"})
+			return
+		}
+
+		filter := bson.M{"job_id": _context.Param("job_id")}
+		if status := _context.Query("status"); status != "" {
+			filter["status"] = status
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		total, err := importRowCollection.CountDocuments(ctx, filter)
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error counting import rows"})
+			return
+		}
+
+		findOptions := options.Find().
+			SetSort(bson.D{{Key: "row", Value: 1}}).
+			SetSkip((page - 1) * limit).
+			SetLimit(limit)
+
+		cursor, err := importRowCollection.Find(ctx, filter, findOptions)
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching import rows"})
+			return
+		}
+		defer cursor.Close(ctx)
+
+		rows := []models.ImportRowResult{}
+		if err = cursor.All(ctx, &rows); err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error decoding import rows"})
+			return
+		}
+
+		_context.JSON(http.StatusOK, gin.H{
+			"data": rows,
+			"pagination": utils.Pagination{
+				Limit:      limit,
+				Page:       page,
+				Total:      &total,
+				TotalPages: (total + limit - 1) / limit,
+				HasMore:    page*limit < total,
+			},
+		})
+	}
+}
+
+// RecoverImportJobs marks jobs that were queued or running when the server
+// stopped as failed, since their spooled uploads did not survive the restart.
+func RecoverImportJobs() {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	_, err := importJobCollection.UpdateMany(
+		ctx,
+		bson.M{"status": bson.M{"$in": bson.A{models.JobStatusQueued, models.JobStatusRunning}}},
+		bson.M{"$set": bson.M{
+			"status":      models.JobStatusFailed,
+			"error":       "server restarted before the import finished, upload the file again",
+			"finished_at": time.Now(),
+		}},
+	)
+	if err != nil {
+		log.Println("Error recovering import jobs:", err)
+	}
+}
+
+type movieImporter struct {
+	job      models.ImportJob
+	validate *validator.Validate
+	seen     map[string]bool
+	counts   models.ImportCounts
+	pending  []interface{}
+}
+
+func runImportJob(job models.ImportJob, path string) {
+	importSlots <- struct{}{}
+	defer func() { <-importSlots }()
+	defer os.Remove(path)
+
+	importer := &movieImporter{
+		job:      job,
+		validate: validator.New(),
+		seen:     map[string]bool{},
+	}
+
+	importer.setStatus(bson.M{"status": models.JobStatusRunning, "started_at": time.Now()})
+
+	err := importer.run(path)
+	if flushErr := importer.flush(); err == nil {
+		err = flushErr
+	}
+
+	if err != nil {
+		log.Printf("Import job %s failed: %v", job.JobID, err)
+		importer.setStatus(bson.M{"status": models.JobStatusFailed, "error": err.Error(), "counts": importer.counts, "finished_at": time.Now()})
+		return
+	}
+
+	importer.setStatus(bson.M{"status": models.JobStatusCompleted, "counts": importer.counts, "finished_at": time.Now()})
+}
+
+func (importer *movieImporter) run(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	switch importer.job.Format {
+	case "csv":
+		return importer.readCSV(file)
+	case "json":
+		return importer.readJSONArray(file)
+	}
+
+	return importer.readNDJSON(file)
+}
+
+func (importer *movieImporter) readCSV(file io.Reader) error {
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return errors.New("could not read CSV header: " + err.Error())
+	}
+
+	index, err := utils.CSVColumnIndex(header)
+	if err != nil {
+		return err
+	}
+
+	for row := 1; ; row++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			if err := importer.record(row, "", models.ImportRowInvalid, parseErr.Error()); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		movie, err := utils.MovieFromCSVRecord(index, record)
+		if err := importer.importRow(row, movie, err); err != nil {
+			return err
+		}
+	}
+}
+
+func (importer *movieImporter) readNDJSON(file io.Reader) error {
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 4<<20)
+
+	row := 0
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		row++
+
+		var movie models.Movie
+		err := json.Unmarshal([]byte(line), &movie)
+		if err != nil {
+			err = errors.New("invalid JSON: " + err.Error())
+		}
+
+		if err := importer.importRow(row, movie, err); err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
+
+// readJSONArray reads the single JSON array written by the json export,
+// decoding one element at a time so large files are never held in memory.
+func (importer *movieImporter) readJSONArray(file io.Reader) error {
+	decoder := json.NewDecoder(file)
+
+	if token, err := decoder.Token(); err != nil || token != json.Delim('[') {
+		return errors.New("JSON upload must be an array of movies")
+	}
+
+	for row := 1; decoder.More(); row++ {
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			// The array itself is broken, so nothing after this point can
+			// be read reliably.
+			return errors.New("invalid JSON at row " + strconv.Itoa(row) + ": " + err.Error())
+		}
+
+		var movie models.Movie
+		err := json.Unmarshal(raw, &movie)
+		if err != nil {
+			err = errors.New("invalid JSON: " + err.Error())
+		}
+
+		if err := importer.importRow(row, movie, err); err != nil {
+			return err
+		}
+	}
+
+	if _, err := decoder.Token(); err != nil {
+		return errors.New("JSON upload is not a complete array: " + err.Error())
+	}
+
+	return nil
+}
+
+// importRow validates one movie and, unless the job is a dry run, writes it.
+// Only database failures are returned; bad rows are recorded in the report.
+func (importer *movieImporter) importRow(row int, movie models.Movie, parseErr error) error {
+	if parseErr != nil {
+		return importer.record(row, movie.ImdbID, models.ImportRowInvalid, parseErr.Error())
+	}
+
+	movie.ID = bson.ObjectID{}
+	movie.Version = 1
+	movie.RankingSource = nil
+	movie.UserRating = nil
+	movie.DeletedAt = nil
+	movie.DeletedBy = ""
+
+	if err := importer.validate.Struct(movie); err != nil {
+		return importer.record(row, movie.ImdbID, models.ImportRowInvalid, err.Error())
+	}
+
+	if importer.seen[movie.ImdbID] {
+		return importer.record(row, movie.ImdbID, models.ImportRowSkipped, "duplicate imdb_id earlier in the file")
+	}
+	importer.seen[movie.ImdbID] = true
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	genres, err := ResolveGenres(ctx, movie.Genre)
+	var unknown *unknownGenresError
+	if errors.As(err, &unknown) {
+		return importer.record(row, movie.ImdbID, models.ImportRowInvalid, err.Error())
+	}
+	if err != nil {
+		return err
+	}
+	movie.Genre = genres
+
+	ranking, err := ResolveRanking(ctx, movie.Ranking)
+	if errors.Is(err, errUnknownRanking) {
+		return importer.record(row, movie.ImdbID, models.ImportRowInvalid, err.Error())
+	}
+	if err != nil {
+		return err
+	}
+	movie.Ranking = ranking
+
+	var existing models.Movie
+	err = movieCollection.FindOne(ctx, bson.M{"imdb_id": movie.ImdbID}).Decode(&existing)
+
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		if !importer.job.DryRun {
+			if err := utils.InsertDocument(ctx, movieCollection, movie); err != nil {
+				return err
+			}
+		}
+		return importer.record(row, movie.ImdbID, models.ImportRowCreated, "")
+	}
+	if err != nil {
+		return err
+	}
+
+	if existing.DeletedAt != nil {
+		return importer.record(row, movie.ImdbID, models.ImportRowSkipped, "movie is in the trash")
+	}
+
+	if importer.job.Mode == models.ImportModeInsert {
+		return importer.record(row, movie.ImdbID, models.ImportRowSkipped, "movie already exists")
+	}
+
+	if sameImportedFields(existing, movie) {
+		return importer.record(row, movie.ImdbID, models.ImportRowSkipped, "no changes")
+	}
+
+	reviewChanged := existing.AdminReview != movie.AdminReview
+	reason := ""
+	if reviewChanged {
+		reason = "new admin review queued for ranking, the file's ranking is not used"
+	}
+
+	if importer.job.DryRun {
+		return importer.record(row, movie.ImdbID, models.ImportRowUpdated, reason)
+	}
+
+	if !sameCatalogFields(existing, movie) {
+		err := utils.UpdateDocument(
+			ctx,
+			movieCollection,
+			utils.ActiveFilter(bson.M{"imdb_id": movie.ImdbID}),
+			bson.M{
+				"$set": bson.M{
+					"title":       movie.Title,
+					"poster_path": movie.PosterPath,
+					"youtube_id":  movie.YoutubeID,
+					"genre":       movie.Genre,
+				},
+				"$inc": bson.M{"version": 1},
+			},
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Review and ranking changes go through the review pipeline, so they are
+	// recorded in the review history. A new review is ranked by a review job
+	// like one saved through the API; a ranking alone is kept as imported.
+	if reviewChanged || existing.Ranking != movie.Ranking {
+		entry := models.ReviewHistoryEntry{
+			ImdbID:    movie.ImdbID,
+			Action:    models.ReviewActionImport,
+			AuthorID:  importer.job.CreatedBy,
+			NewReview: movie.AdminReview,
+		}
+		if !reviewChanged {
+			entry.Ranking = movie.Ranking
+			entry.RankingStatus = models.RankingStatusRanked
+		}
+
+		if _, err := saveAdminReview(ctx, entry); err != nil {
+			return err
+		}
+	}
+
+	return importer.record(row, movie.ImdbID, models.ImportRowUpdated, reason)
+}
+
+// sameCatalogFields compares the fields an import sets directly, leaving out
+// the review and ranking.
+func sameCatalogFields(a models.Movie, b models.Movie) bool {
+	return a.Title == b.Title &&
+		a.PosterPath == b.PosterPath &&
+		a.YoutubeID == b.YoutubeID &&
+		slices.Equal(a.Genre, b.Genre)
+}
+
+func sameImportedFields(a models.Movie, b models.Movie) bool {
+	return sameCatalogFields(a, b) &&
+		a.AdminReview == b.AdminReview &&
+		a.Ranking == b.Ranking
+}
+
+func (importer *movieImporter) record(row int, imdbID string, status string, reason string) error {
+	importer.counts.Total++
+
+	switch status {
+	case models.ImportRowCreated:
+		importer.counts.Created++
+	case models.ImportRowUpdated:
+		importer.counts.Updated++
+	case models.ImportRowSkipped:
+		importer.counts.Skipped++
+	case models.ImportRowInvalid:
+		importer.counts.Invalid++
+	}
+
+	importer.pending = append(importer.pending, models.ImportRowResult{
+		JobID:  importer.job.JobID,
+		Row:    row,
+		ImdbID: imdbID,
+		Status: status,
+		Reason: reason,
+	})
+
+	if len(importer.pending) >= importRowBatchSize {
+		return importer.flush()
+	}
+
+	return nil
+}
+
+// flush writes the buffered row results and publishes the running counts so
+// that the status endpoint shows progress while the job is still going.
+func (importer *movieImporter) flush() error {
+	if len(importer.pending) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	if _, err := importRowCollection.InsertMany(ctx, importer.pending); err != nil {
+		return err
+	}
+	importer.pending = importer.pending[:0]
+
+	return utils.UpdateDocument(ctx, importJobCollection, bson.M{"job_id": importer.job.JobID}, bson.M{"$set": bson.M{"counts": importer.counts}})
+}
+
+func (importer *movieImporter) setStatus(fields bson.M) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	if err := utils.UpdateDocument(ctx, importJobCollection, bson.M{"job_id": importer.job.JobID}, bson.M{"$set": fields}); err != nil {
+		log.Printf("Error updating import job %s: %v", importer.job.JobID, err)
+	}
+}
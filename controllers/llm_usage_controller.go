@@ -0,0 +1,437 @@
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	db "github.com/Neph-dev/MovieStreamServer/database"
+	"github.com/Neph-dev/MovieStreamServer/llm"
+	"github.com/Neph-dev/MovieStreamServer/models"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+var llmCacheCollection *mongo.Collection = db.OpenCollection("llm_cache")
+var llmUsageCollection *mongo.Collection = db.OpenCollection("llm_usage")
+
+// ErrLLMBudgetExceeded is returned instead of calling the model once today's
+// usage has reached LLM_DAILY_BUDGET_USD or LLM_DAILY_TOKEN_BUDGET.
+var ErrLLMBudgetExceeded = errors.New("daily LLM budget exceeded")
+
+var llmIndexesOnce sync.Once
+
+func ensureLLMIndexes(ctx context.Context) {
+	llmIndexesOnce.Do(func() {
+		_, err := llmCacheCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+			{Keys: bson.D{{Key: "key", Value: 1}}, Options: options.Index().SetUnique(true)},
+			{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+		})
+		if err == nil {
+			_, err = llmUsageCollection.Indexes().CreateOne(ctx, mongo.IndexModel{Keys: bson.D{{Key: "created_at", Value: 1}}})
+		}
+		if err != nil {
+			log.Println("Warning: could not create LLM cache and usage indexes:", err)
+		}
+	})
+}
+
+func envFloat(key string) float64 {
+	value, err := strconv.ParseFloat(os.Getenv(key), 64)
+	if err != nil || value < 0 {
+		return 0
+	}
+
+	return value
+}
+
+// llmCacheTTL is how long a cached ranking is reused; LLM_CACHE_TTL_HOURS=0
+// turns the cache off.
+func llmCacheTTL() time.Duration {
+	hours := 720
+
+	if value := os.Getenv("LLM_CACHE_TTL_HOURS"); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil && parsed >= 0 {
+			hours = parsed
+		}
+	}
+
+	return time.Duration(hours) * time.Hour
+}
+
+func reviewHash(review string) string {
+	sum := sha256.Sum256([]byte(strings.TrimSpace(review)))
+	return hex.EncodeToString(sum[:])
+}
+
+func llmCacheKey(promptVersion int, provider string, model string, hash string) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%d\x00%s\x00%s\x00%s", promptVersion, provider, model, hash)))
+	return hex.EncodeToString(sum[:])
+}
+
+// lookupCachedRanking returns a live cache entry whose ranking is still on
+// the scale; entries pointing at a removed or renamed ranking are misses.
+func lookupCachedRanking(ctx context.Context, key string, rankings []models.RankingDefinition) (models.LLMCacheEntry, bool) {
+	var entry models.LLMCacheEntry
+
+	if llmCacheTTL() == 0 {
+		return entry, false
+	}
+
+	err := llmCacheCollection.FindOne(ctx, bson.M{"key": key, "expires_at": bson.M{"$gt": time.Now()}}).Decode(&entry)
+	if err != nil {
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			log.Println("Error reading LLM cache:", err)
+		}
+		return entry, false
+	}
+
+	for _, ranking := range rankings {
+		if !ranking.IsDefault && ranking.Ranking() == entry.Ranking {
+			return entry, true
+		}
+	}
+
+	return entry, false
+}
+
+func storeCachedRanking(ctx context.Context, entry models.LLMCacheEntry) {
+	ttl := llmCacheTTL()
+	if ttl == 0 {
+		return
+	}
+
+	entry.CreatedAt = time.Now()
+	entry.ExpiresAt = entry.CreatedAt.Add(ttl)
+
+	_, err := llmCacheCollection.UpdateOne(ctx, bson.M{"key": entry.Key}, bson.M{"$set": entry}, options.UpdateOne().SetUpsert(true))
+	if err != nil {
+		log.Println("Error writing LLM cache:", err)
+	}
+}
+
+func estimateLLMCost(promptTokens int, completionTokens int) float64 {
+	return float64(promptTokens)/1000*envFloat("LLM_PROMPT_COST_PER_1K") +
+		float64(completionTokens)/1000*envFloat("LLM_COMPLETION_COST_PER_1K")
+}
+
+func recordLLMUsage(usage models.LLMUsage) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, err := llmUsageCollection.InsertOne(ctx, usage); err != nil {
+		log.Println("Error recording LLM usage:", err)
+	}
+}
+
+func startOfDay(t time.Time) time.Time {
+	year, month, day := t.UTC().Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// llmSpendToday sums the cost and tokens of today's (UTC) model calls.
+func llmSpendToday(ctx context.Context) (float64, int64, error) {
+	cursor, err := llmUsageCollection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"created_at": bson.M{"$gte": startOfDay(time.Now())}, "cached": false}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":    nil,
+			"cost":   bson.M{"$sum": "$cost_usd"},
+			"tokens": bson.M{"$sum": bson.M{"$add": bson.A{"$prompt_tokens", "$completion_tokens"}}},
+		}}},
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var totals []struct {
+		Cost   float64 `bson:"cost"`
+		Tokens int64   `bson:"tokens"`
+	}
+	if err := cursor.All(ctx, &totals); err != nil || len(totals) == 0 {
+		return 0, 0, err
+	}
+
+	return totals[0].Cost, totals[0].Tokens, nil
+}
+
+// checkLLMBudget refuses a model call once a daily cap is reached. Calls
+// already in flight can overshoot the cap by their own cost.
+func checkLLMBudget(ctx context.Context) error {
+	costCap := envFloat("LLM_DAILY_BUDGET_USD")
+	tokenCap := int64(envFloat("LLM_DAILY_TOKEN_BUDGET"))
+	if costCap == 0 && tokenCap == 0 {
+		return nil
+	}
+
+	cost, tokens, err := llmSpendToday(ctx)
+	if err != nil {
+		return err
+	}
+
+	if (costCap > 0 && cost >= costCap) || (tokenCap > 0 && tokens >= tokenCap) {
+		return ErrLLMBudgetExceeded
+	}
+
+	return nil
+}
+
+// rankReviewWithAccounting answers from the cache when it can, otherwise
+// checks the daily budget and asks the ranker. Either way the request is
+// recorded in the usage collection.
+func rankReviewWithAccounting(ctx context.Context, ranker llm.ReviewRanker, input llm.ReviewInput, rankings []models.RankingDefinition, imdbID string, userId string) (llm.RankingResult, error) {
+	ensureLLMIndexes(ctx)
+
+	provider, model := ranker.ModelInfo()
+
+	promptVersion := 0
+	if input.Prompt != nil {
+		promptVersion = input.Prompt.Version
+	}
+
+	hash := reviewHash(input.Review)
+	key := llmCacheKey(promptVersion, provider, model, hash)
+
+	usage := models.LLMUsage{
+		Purpose:       models.LLMPurposeRanking,
+		Provider:      provider,
+		Model:         model,
+		PromptVersion: promptVersion,
+		UserID:        userId,
+		ImdbID:        imdbID,
+		CreatedAt:     time.Now(),
+	}
+
+	if entry, ok := lookupCachedRanking(ctx, key, rankings); ok {
+		usage.Cached = true
+		recordLLMUsage(usage)
+
+		return llm.RankingResult{
+			Ranking:       entry.Ranking,
+			Provider:      provider,
+			Model:         model,
+			PromptVersion: promptVersion,
+			RawResponse:   entry.RawResponse,
+		}, nil
+	}
+
+	if err := checkLLMBudget(ctx); err != nil {
+		return llm.RankingResult{}, err
+	}
+
+	started := time.Now()
+	result, err := ranker.RankReview(ctx, input, rankings)
+
+	usage.LatencyMs = time.Since(started).Milliseconds()
+	usage.Attempts = result.Attempts
+	usage.PromptTokens = result.PromptTokens
+	usage.CompletionTokens = result.CompletionTokens
+
+	// Providers that report no usage are counted locally; the rule-based
+	// ranker uses no tokens at all.
+	if provider != llm.ProviderRuleBased {
+		if usage.PromptTokens == 0 {
+			usage.PromptTokens = llm.CountTokens(model, result.Prompt) * max(result.Attempts, 1)
+		}
+		if usage.CompletionTokens == 0 {
+			usage.CompletionTokens = llm.CountTokens(model, result.RawResponse)
+		}
+	}
+
+	usage.CostUSD = estimateLLMCost(usage.PromptTokens, usage.CompletionTokens)
+	if err != nil {
+		usage.Error = err.Error()
+	}
+	recordLLMUsage(usage)
+
+	if err == nil {
+		storeCachedRanking(ctx, models.LLMCacheEntry{
+			Key:           key,
+			PromptVersion: promptVersion,
+			Provider:      provider,
+			Model:         model,
+			ReviewHash:    hash,
+			Ranking:       result.Ranking,
+			RawResponse:   result.RawResponse,
+		})
+	}
+
+	return result, err
+}
+
+type llmUsageRow struct {
+	Day              string  `bson:"day" json:"day"`
+	Purpose          string  `bson:"purpose" json:"purpose"`
+	Model            string  `bson:"model" json:"model"`
+	UserID           string  `bson:"user_id" json:"user_id"`
+	Email            string  `bson:"email,omitempty" json:"email,omitempty"`
+	Calls            int64   `bson:"calls" json:"calls"`
+	CachedCalls      int64   `bson:"cached_calls" json:"cached_calls"`
+	Errors           int64   `bson:"errors" json:"errors"`
+	PromptTokens     int64   `bson:"prompt_tokens" json:"prompt_tokens"`
+	CompletionTokens int64   `bson:"completion_tokens" json:"completion_tokens"`
+	CostUSD          float64 `bson:"cost_usd" json:"cost_usd"`
+	AvgLatencyMs     float64 `bson:"avg_latency_ms" json:"avg_latency_ms"`
+}
+
+// GetLLMUsage reports LLM usage per day (UTC), purpose, model and user
+// between the from and to dates (YYYY-MM-DD, the last 30 days by default),
+// with today's spend against the daily budget.
+func GetLLMUsage() gin.HandlerFunc {
+	return func(_context *gin.Context) {
+		to := startOfDay(time.Now())
+		if value := _context.Query("to"); value != "" {
+			parsed, err := time.Parse(time.DateOnly, value)
+			if err != nil {
+				_context.JSON(http.StatusBadRequest, gin.H{"error": "to must be a date in YYYY-MM-DD format"})
+				return
+			}
+			to = parsed
+		}
+
+		from := to.AddDate(0, 0, -29)
+		if value := _context.Query("from"); value != "" {
+			parsed, err := time.Parse(time.DateOnly, value)
+			if err != nil {
+				_context.JSON(http.StatusBadRequest, gin.H{"error": "from must be a date in YYYY-MM-DD format"})
+				return
+			}
+			from = parsed
+		}
+
+		if from.After(to) {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": "from must not be after to"})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		pipeline := mongo.Pipeline{
+			{{Key: "$match", Value: bson.M{"created_at": bson.M{"$gte": from, "$lt": to.AddDate(0, 0, 1)}}}},
+			{{Key: "$group", Value: bson.M{
+				"_id": bson.M{
+					"day":     bson.M{"$dateToString": bson.M{"format": "%Y-%m-%d", "date": "$created_at"}},
+					"purpose": "$purpose",
+					"model":   "$model",
+					"user_id": "$user_id",
+				},
+				"calls":             bson.M{"$sum": 1},
+				"cached_calls":      bson.M{"$sum": bson.M{"$cond": bson.A{"$cached", 1, 0}}},
+				"errors":            bson.M{"$sum": bson.M{"$cond": bson.A{bson.M{"$ifNull": bson.A{"$error", false}}, 1, 0}}},
+				"prompt_tokens":     bson.M{"$sum": "$prompt_tokens"},
+				"completion_tokens": bson.M{"$sum": "$completion_tokens"},
+				"cost_usd":          bson.M{"$sum": "$cost_usd"},
+				"avg_latency_ms":    bson.M{"$avg": bson.M{"$cond": bson.A{"$cached", nil, "$latency_ms"}}},
+			}}},
+			{{Key: "$lookup", Value: bson.M{"from": "users", "localField": "_id.user_id", "foreignField": "user_id", "as": "user"}}},
+			{{Key: "$project", Value: bson.M{
+				"_id":               0,
+				"day":               "$_id.day",
+				"purpose":           "$_id.purpose",
+				"model":             "$_id.model",
+				"user_id":           "$_id.user_id",
+				"email":             bson.M{"$arrayElemAt": bson.A{"$user.email", 0}},
+				"calls":             1,
+				"cached_calls":      1,
+				"errors":            1,
+				"prompt_tokens":     1,
+				"completion_tokens": 1,
+				"cost_usd":          1,
+				"avg_latency_ms":    bson.M{"$ifNull": bson.A{"$avg_latency_ms", 0}},
+			}}},
+			{{Key: "$sort", Value: bson.D{{Key: "day", Value: -1}, {Key: "purpose", Value: 1}, {Key: "model", Value: 1}, {Key: "user_id", Value: 1}}}},
+		}
+
+		cursor, err := llmUsageCollection.Aggregate(ctx, pipeline)
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error aggregating LLM usage"})
+			return
+		}
+		defer cursor.Close(ctx)
+
+		rows := []llmUsageRow{}
+		if err := cursor.All(ctx, &rows); err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error decoding LLM usage"})
+			return
+		}
+
+		var totals llmUsageRow
+		for _, row := range rows {
+			totals.Calls += row.Calls
+			totals.CachedCalls += row.CachedCalls
+			totals.Errors += row.Errors
+			totals.PromptTokens += row.PromptTokens
+			totals.CompletionTokens += row.CompletionTokens
+			totals.CostUSD += row.CostUSD
+		}
+
+		spentToday, tokensToday, err := llmSpendToday(ctx)
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error aggregating LLM usage"})
+			return
+		}
+
+		_context.JSON(http.StatusOK, gin.H{
+			"from": from.Format(time.DateOnly),
+			"to":   to.Format(time.DateOnly),
+			"data": rows,
+			"totals": gin.H{
+				"calls":             totals.Calls,
+				"cached_calls":      totals.CachedCalls,
+				"errors":            totals.Errors,
+				"prompt_tokens":     totals.PromptTokens,
+				"completion_tokens": totals.CompletionTokens,
+				"cost_usd":          totals.CostUSD,
+			},
+			"budget": gin.H{
+				"daily_budget_usd":   envFloat("LLM_DAILY_BUDGET_USD"),
+				"daily_token_budget": int64(envFloat("LLM_DAILY_TOKEN_BUDGET")),
+				"spent_usd_today":    spentToday,
+				"tokens_today":       tokensToday,
+			},
+		})
+	}
+}
+
+// InvalidateLLMCache drops cached rankings, all of them or only those of a
+// prompt_version and/or model.
+func InvalidateLLMCache() gin.HandlerFunc {
+	return func(_context *gin.Context) {
+		filter := bson.M{}
+
+		if value := _context.Query("prompt_version"); value != "" {
+			version, err := strconv.Atoi(value)
+			if err != nil {
+				_context.JSON(http.StatusBadRequest, gin.H{"error": "prompt_version must be an integer"})
+				return
+			}
+			filter["prompt_version"] = version
+		}
+		if model := _context.Query("model"); model != "" {
+			filter["model"] = model
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		result, err := llmCacheCollection.DeleteMany(ctx, filter)
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error invalidating LLM cache"})
+			return
+		}
+
+		_context.JSON(http.StatusOK, gin.H{"message": "LLM cache invalidated", "deleted": result.DeletedCount})
+	}
+}
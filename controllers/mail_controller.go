@@ -0,0 +1,62 @@
+package controllers
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/Neph-dev/MovieStreamServer/mailer"
+)
+
+var appMailer mailer.Mailer
+var appMailerMutex sync.Mutex
+
+// SetMailer replaces the mailer selected from the environment.
+func SetMailer(m mailer.Mailer) {
+	appMailerMutex.Lock()
+	defer appMailerMutex.Unlock()
+
+	appMailer = m
+}
+
+func getMailer() (mailer.Mailer, error) {
+	appMailerMutex.Lock()
+	defer appMailerMutex.Unlock()
+
+	if appMailer == nil {
+		m, err := mailer.NewMailerFromEnv()
+		if err != nil {
+			return nil, err
+		}
+		appMailer = m
+	}
+
+	return appMailer, nil
+}
+
+func sendMail(ctx context.Context, message mailer.Message) error {
+	m, err := getMailer()
+	if err != nil {
+		return err
+	}
+
+	return m.Send(ctx, message)
+}
+
+// tokenInstructions tells the user how to use an emailed token: as a link
+// into the client when APP_BASE_URL is set, and always as the raw token.
+func tokenInstructions(path string, token string) string {
+	var builder strings.Builder
+
+	if base := strings.TrimRight(os.Getenv("APP_BASE_URL"), "/"); base != "" {
+		builder.WriteString(base + path + "?token=" + url.QueryEscape(token) + "\n\n")
+		builder.WriteString("Or use this code: ")
+	} else {
+		builder.WriteString("Use this code: ")
+	}
+	builder.WriteString(token + "\n")
+
+	return builder.String()
+}
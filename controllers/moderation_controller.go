@@ -0,0 +1,405 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Neph-dev/MovieStreamServer/llm"
+	"github.com/Neph-dev/MovieStreamServer/models"
+	"github.com/Neph-dev/MovieStreamServer/utils"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+var reviewModerator llm.ReviewModerator
+var reviewModeratorLoaded bool
+var reviewModeratorMutex sync.Mutex
+
+var moderationStatuses = []string{models.ModerationPending, models.ModerationApproved, models.ModerationFlagged, models.ModerationRejected}
+
+// SetReviewModerator replaces the moderator selected from the environment;
+// nil leaves moderation to the rules alone.
+func SetReviewModerator(moderator llm.ReviewModerator) {
+	reviewModeratorMutex.Lock()
+	defer reviewModeratorMutex.Unlock()
+
+	reviewModerator = moderator
+	reviewModeratorLoaded = true
+}
+
+func getReviewModerator() (llm.ReviewModerator, error) {
+	reviewModeratorMutex.Lock()
+	defer reviewModeratorMutex.Unlock()
+
+	if !reviewModeratorLoaded {
+		moderator, err := llm.NewReviewModeratorFromEnv()
+		if err != nil {
+			return nil, err
+		}
+		reviewModerator = moderator
+		reviewModeratorLoaded = true
+	}
+
+	return reviewModerator, nil
+}
+
+// initialModeration is the moderation a review gets when it is written.
+// Rating-only reviews have nothing to moderate, and reviews caught by the
+// rules go straight to the moderator queue; the rest wait for the model.
+func initialModeration(text string) *models.ReviewModeration {
+	now := time.Now()
+
+	if text == "" {
+		return &models.ReviewModeration{Status: models.ModerationApproved, Source: models.ModeratedByAuto, ModeratedAt: &now}
+	}
+
+	matches := llm.CheckModerationRules(text)
+	if len(matches) == 0 {
+		return &models.ReviewModeration{Status: models.ModerationPending}
+	}
+
+	var labels, rules []string
+	for _, match := range matches {
+		if !slices.Contains(labels, match.Label) {
+			labels = append(labels, match.Label)
+		}
+		rules = append(rules, match.Rule)
+	}
+
+	return &models.ReviewModeration{
+		Status:      models.ModerationFlagged,
+		Labels:      labels,
+		Reason:      "matched rules: " + strings.Join(rules, ", "),
+		Source:      models.ModeratedByRules,
+		ModeratedAt: &now,
+	}
+}
+
+// setModeration moves the review matched by filter to a new moderation state
+// and moves its rating in or out of the movie's aggregate accordingly.
+func setModeration(ctx context.Context, filter bson.M, moderation models.ReviewModeration) (models.UserReview, error) {
+	var review models.UserReview
+
+	err := withTransaction(ctx, func(ctx context.Context) error {
+		var previous models.UserReview
+		err := userReviewCollection.FindOneAndUpdate(
+			ctx,
+			filter,
+			bson.M{"$set": bson.M{"moderation": moderation}},
+			options.FindOneAndUpdate().SetReturnDocument(options.Before),
+		).Decode(&previous)
+		if err != nil {
+			return err
+		}
+
+		review = previous
+		review.Moderation = &moderation
+
+		err = applyRatingChange(ctx, bson.M{"imdb_id": review.ImdbID}, previous.CountedRating(), review.CountedRating())
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			// The movie was purged; there is no aggregate left.
+			return nil
+		}
+		return err
+	})
+
+	return review, err
+}
+
+// moderateUserReview asks the model about a pending review. Reviews it
+// labels go to the moderator queue, the others are approved. When the model
+// fails the review stays pending for the sweeper, until
+// MODERATION_MAX_ATTEMPTS is reached and it is queued for a moderator.
+func moderateUserReview(review models.UserReview) {
+	ctx, cancel := context.WithTimeout(context.Background(), llmTotalTimeout())
+	defer cancel()
+
+	// Only this version of the review, still pending, may be decided.
+	filter := bson.M{"_id": review.ID, "updated_at": review.UpdatedAt, "moderation.status": models.ModerationPending}
+
+	now := time.Now()
+	decision := models.ReviewModeration{Status: models.ModerationApproved, Source: models.ModeratedByRules, ModeratedAt: &now}
+
+	moderator, err := getReviewModerator()
+	if err == nil && moderator != nil {
+		var result llm.ModerationResult
+		result, err = moderateWithAccounting(ctx, moderator, review)
+
+		if err == nil {
+			decision.Source = models.ModeratedByLLM
+			decision.Model = result.Model
+			decision.Labels = result.Labels
+			decision.Reason = result.Reason
+			if len(result.Labels) > 0 {
+				decision.Status = models.ModerationFlagged
+			}
+		}
+	}
+
+	// An exhausted budget says nothing about the review, so it stays pending
+	// for a later sweep without spending one of its attempts.
+	if errors.Is(err, ErrLLMBudgetExceeded) {
+		log.Printf("Moderation of review %s deferred: %v", review.ID.Hex(), err)
+		return
+	}
+
+	if err != nil {
+		attempts := review.Moderation.Attempts + 1
+		log.Printf("Moderation of review %s failed (attempt %d): %v", review.ID.Hex(), attempts, err)
+
+		if attempts < utils.GetEnvInt("MODERATION_MAX_ATTEMPTS", 5) {
+			if _, err := userReviewCollection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"moderation.attempts": attempts}}); err != nil {
+				log.Printf("Error updating moderation of review %s: %v", review.ID.Hex(), err)
+			}
+			return
+		}
+
+		decision = models.ReviewModeration{
+			Status:      models.ModerationFlagged,
+			Reason:      "automatic moderation failed: " + err.Error(),
+			Source:      models.ModeratedByLLM,
+			Attempts:    attempts,
+			ModeratedAt: &now,
+		}
+	}
+
+	if _, err := setModeration(ctx, filter, decision); err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		log.Printf("Error saving moderation of review %s: %v", review.ID.Hex(), err)
+	}
+}
+
+// moderateWithAccounting checks the daily LLM budget and records the call in
+// the usage collection, as rankReviewWithAccounting does for rankings.
+func moderateWithAccounting(ctx context.Context, moderator llm.ReviewModerator, review models.UserReview) (llm.ModerationResult, error) {
+	ensureLLMIndexes(ctx)
+
+	if err := checkLLMBudget(ctx); err != nil {
+		return llm.ModerationResult{}, err
+	}
+
+	var movie models.Movie
+	if err := movieCollection.FindOne(ctx, bson.M{"imdb_id": review.ImdbID}).Decode(&movie); err != nil {
+		return llm.ModerationResult{}, err
+	}
+
+	provider, model := moderator.ModelInfo()
+
+	started := time.Now()
+	result, err := moderator.ModerateReview(ctx, movie.Title, review.Review)
+
+	usage := models.LLMUsage{
+		Purpose:          models.LLMPurposeModeration,
+		Provider:         provider,
+		Model:            model,
+		UserID:           review.UserID,
+		ImdbID:           review.ImdbID,
+		Attempts:         1,
+		PromptTokens:     result.PromptTokens,
+		CompletionTokens: result.CompletionTokens,
+		LatencyMs:        time.Since(started).Milliseconds(),
+		CreatedAt:        started,
+	}
+	if usage.PromptTokens == 0 {
+		usage.PromptTokens = llm.CountTokens(model, result.Prompt)
+	}
+	if usage.CompletionTokens == 0 {
+		usage.CompletionTokens = llm.CountTokens(model, result.RawResponse)
+	}
+	usage.CostUSD = estimateLLMCost(usage.PromptTokens, usage.CompletionTokens)
+	if err != nil {
+		usage.Error = err.Error()
+	}
+	recordLLMUsage(usage)
+
+	return result, err
+}
+
+// StartModerationSweeper periodically retries reviews left pending, whether
+// their moderation failed or the server stopped before it finished.
+func StartModerationSweeper() {
+	interval := time.Duration(utils.GetEnvInt("MODERATION_SWEEP_INTERVAL_SECONDS", 60)) * time.Second
+
+	go func() {
+		for {
+			sweepPendingModeration(interval)
+			time.Sleep(interval)
+		}
+	}()
+}
+
+func sweepPendingModeration(interval time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	// Reviews saved within the last interval are still being moderated by
+	// the request that saved them.
+	cursor, err := userReviewCollection.Find(
+		ctx,
+		bson.M{"moderation.status": models.ModerationPending, "updated_at": bson.M{"$lt": time.Now().Add(-interval)}},
+		options.Find().SetSort(bson.D{{Key: "updated_at", Value: 1}}).SetLimit(50),
+	)
+	if err != nil {
+		log.Println("Error fetching pending reviews:", err)
+		return
+	}
+
+	var reviews []models.UserReview
+	err = cursor.All(ctx, &reviews)
+	cursor.Close(ctx)
+	if err != nil {
+		log.Println("Error fetching pending reviews:", err)
+		return
+	}
+
+	for _, review := range reviews {
+		moderateUserReview(review)
+	}
+}
+
+// ListModerationQueue pages through the reviews in a moderation state,
+// flagged ones by default, oldest first.
+func ListModerationQueue() gin.HandlerFunc {
+	return func(_context *gin.Context) {
+		limit, err := utils.GetLimitParam(_context, 20, 100)
+		if err != nil {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		page, err := strconv.ParseInt(_context.DefaultQuery("page", "1"), 10, 64)
+		if err != nil || page < 1 {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": "page must be a positive integer"})
+			return
+		}
+
+		status := _context.DefaultQuery("status", models.ModerationFlagged)
+		if !slices.Contains(moderationStatuses, status) {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": "status must be one of " + strings.Join(moderationStatuses, ", ")})
+			return
+		}
+
+		filter := bson.M{"moderation.status": status}
+		if label := _context.Query("label"); label != "" {
+			filter["moderation.labels"] = label
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		total, err := userReviewCollection.CountDocuments(ctx, filter)
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error counting reviews"})
+			return
+		}
+
+		findOptions := options.Find().
+			SetSort(bson.D{{Key: "updated_at", Value: 1}}).
+			SetSkip((page - 1) * limit).
+			SetLimit(limit)
+
+		cursor, err := userReviewCollection.Find(ctx, filter, findOptions)
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching reviews"})
+			return
+		}
+		defer cursor.Close(ctx)
+
+		reviews := []models.UserReview{}
+		if err = cursor.All(ctx, &reviews); err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error decoding reviews"})
+			return
+		}
+
+		_context.JSON(http.StatusOK, gin.H{
+			"data": reviews,
+			"pagination": utils.Pagination{
+				Limit:      limit,
+				Page:       page,
+				Total:      &total,
+				TotalPages: (total + limit - 1) / limit,
+				HasMore:    page*limit < total,
+			},
+		})
+	}
+}
+
+func ApproveUserReview() gin.HandlerFunc {
+	return moderateReviewAction(models.ModerationApproved)
+}
+
+func RejectUserReview() gin.HandlerFunc {
+	return moderateReviewAction(models.ModerationRejected)
+}
+
+// moderateReviewAction records a moderator's decision on a review, with an
+// optional note explaining it to the author.
+func moderateReviewAction(status string) gin.HandlerFunc {
+	return func(_context *gin.Context) {
+		reviewID, err := bson.ObjectIDFromHex(_context.Param("review_id"))
+		if err != nil {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": "Invalid review id"})
+			return
+		}
+
+		userId, err := utils.GetDataFromContext(_context, "userId")
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving user data from context"})
+			return
+		}
+
+		var request struct {
+			Note string `json:"note"`
+		}
+		if _context.Request.ContentLength > 0 {
+			if err := _context.BindJSON(&request); err != nil {
+				_context.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+				return
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var current models.UserReview
+		if err := userReviewCollection.FindOne(ctx, bson.M{"_id": reviewID}).Decode(&current); err != nil {
+			_context.JSON(http.StatusNotFound, gin.H{"error": "Review not found"})
+			return
+		}
+
+		now := time.Now()
+		moderation := models.ReviewModeration{
+			Status:      status,
+			Source:      models.ModeratedByModerator,
+			ModeratorID: userId,
+			Note:        strings.TrimSpace(request.Note),
+			ModeratedAt: &now,
+		}
+		if current.Moderation != nil {
+			moderation.Labels = current.Moderation.Labels
+			moderation.Reason = current.Moderation.Reason
+		}
+
+		// Matching updated_at keeps a decision from landing on an edit the
+		// moderator has not seen.
+		review, err := setModeration(ctx, bson.M{"_id": reviewID, "updated_at": current.UpdatedAt}, moderation)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			_context.JSON(http.StatusConflict, gin.H{"error": "Review was edited by its author, fetch it again"})
+			return
+		}
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error saving moderation decision"})
+			return
+		}
+
+		_context.JSON(http.StatusOK, review)
+	}
+}
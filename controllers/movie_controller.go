@@ -2,49 +2,273 @@ package controllers
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
+	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	db "github.com/Neph-dev/MovieStreamServer/database"
+	"github.com/Neph-dev/MovieStreamServer/llm"
 	"github.com/Neph-dev/MovieStreamServer/models"
 	"github.com/Neph-dev/MovieStreamServer/utils"
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
 	"github.com/joho/godotenv"
-	"github.com/tmc/langchaingo/llms/openai"
 	"go.mongodb.org/mongo-driver/v2/bson"
 	"go.mongodb.org/mongo-driver/v2/mongo"
 	"go.mongodb.org/mongo-driver/v2/mongo/options"
 )
 
 var movieCollection *mongo.Collection = db.OpenCollection("movies")
-var rankingCollection *mongo.Collection = db.OpenCollection("rankings")
+var rankingCollection *mongo.Collection = movieCollection.Database().Collection("rankings")
+
+var movieSortFields = map[string]string{
+	"title":   "title",
+	"ranking": "ranking.ranking_value",
+	"created": "_id",
+}
+
+// BuildMovieFilter turns the listing query parameters (genre, min_ranking,
+// max_ranking, title_prefix) into a Mongo filter. Every endpoint that lists
+// movies accepts the same parameters.
+func BuildMovieFilter(_context *gin.Context) (bson.M, error) {
+	return MovieFilterFromQuery(_context.Request.URL.Query())
+}
+
+func MovieFilterFromQuery(query url.Values) (bson.M, error) {
+	filter := utils.ActiveFilter(bson.M{})
+
+	var genres []string
+	for _, value := range query["genre"] {
+		for _, genre := range strings.Split(value, ",") {
+			if genre = strings.TrimSpace(genre); genre != "" {
+				genres = append(genres, genre)
+			}
+		}
+	}
+	if len(genres) > 0 {
+		filter["genre.genre_name"] = bson.M{"$in": genres}
+	}
+
+	rankingRange := bson.M{}
+	if value := query.Get("min_ranking"); value != "" {
+		minRanking, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, errors.New("min_ranking must be an integer")
+		}
+		rankingRange["$gte"] = minRanking
+	}
+	if value := query.Get("max_ranking"); value != "" {
+		maxRanking, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, errors.New("max_ranking must be an integer")
+		}
+		rankingRange["$lte"] = maxRanking
+	}
+	if len(rankingRange) > 0 {
+		filter["ranking.ranking_value"] = rankingRange
+	}
+
+	if prefix := strings.TrimSpace(query.Get("title_prefix")); prefix != "" {
+		filter["title"] = bson.M{"$regex": "^" + regexp.QuoteMeta(prefix), "$options": "i"}
+	}
+
+	return filter, nil
+}
+
+func getMovieSort(_context *gin.Context) (string, int, error) {
+	sortKey := _context.DefaultQuery("sort", "created")
+	if _, ok := movieSortFields[sortKey]; !ok {
+		return "", 0, errors.New("sort must be one of title, ranking, created")
+	}
+
+	switch _context.DefaultQuery("order", "asc") {
+	case "asc":
+		return sortKey, 1, nil
+	case "desc":
+		return sortKey, -1, nil
+	default:
+		return "", 0, errors.New("order must be asc or desc")
+	}
+}
+
+func movieSortValue(movie models.Movie, sortKey string) interface{} {
+	switch sortKey {
+	case "title":
+		return movie.Title
+	case "ranking":
+		return movie.Ranking.RankingValue
+	default:
+		return nil
+	}
+}
+
+// keysetFilter selects the documents strictly after the cursor position in
+// (sort field, _id) order.
+func keysetFilter(pageCursor utils.PageCursor) (bson.M, error) {
+	lastID, err := bson.ObjectIDFromHex(pageCursor.ID)
+	if err != nil {
+		return nil, errors.New("malformed cursor")
+	}
+
+	operator := "$gt"
+	if pageCursor.Order < 0 {
+		operator = "$lt"
+	}
+
+	field := movieSortFields[pageCursor.Sort]
+	if field == "_id" {
+		return bson.M{"_id": bson.M{operator: lastID}}, nil
+	}
+
+	value := pageCursor.Value
+	if number, ok := value.(float64); ok {
+		value = int(number)
+	}
+
+	return bson.M{"$or": bson.A{
+		bson.M{field: bson.M{operator: value}},
+		bson.M{field: value, "_id": bson.M{operator: lastID}},
+	}}, nil
+}
 
 func GetMovies() gin.HandlerFunc {
 	return func(_context *gin.Context) {
 		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
 
-		var movies []models.Movie
+		filter, err := BuildMovieFilter(_context)
+		if err != nil {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		sortKey, order, err := getMovieSort(_context)
+		if err != nil {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		limit, err := utils.GetLimitParam(_context, 20, 100)
+		if err != nil {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		sortField := movieSortFields[sortKey]
+		sort := bson.D{{Key: sortField, Value: order}}
+		if sortField != "_id" {
+			sort = append(sort, bson.E{Key: "_id", Value: order})
+		}
+
+		findOptions := options.Find().SetSort(sort).SetLimit(limit + 1)
+		pagination := utils.Pagination{Limit: limit}
+		links := map[string]string{}
+		query := filter
+
+		cursorToken := _context.Query("cursor")
+		pageParam := _context.Query("page")
+
+		if cursorToken != "" {
+			pageCursor, err := utils.DecodeCursor(cursorToken)
+			if err != nil {
+				_context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			if pageCursor.Sort != sortKey || pageCursor.Order != order {
+				_context.JSON(http.StatusBadRequest, gin.H{"error": "cursor does not match the requested sort"})
+				return
+			}
+
+			after, err := keysetFilter(pageCursor)
+			if err != nil {
+				_context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
 
-		cursor, err := movieCollection.Find(ctx, bson.M{})
+			query = bson.M{"$and": bson.A{filter, after}}
+		} else if pageParam != "" {
+			page, err := strconv.ParseInt(pageParam, 10, 64)
+			if err != nil || page < 1 {
+				_context.JSON(http.StatusBadRequest, gin.H{"error": "page must be a positive integer"})
+				return
+			}
+
+			total, err := movieCollection.CountDocuments(ctx, filter)
+			if err != nil {
+				_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error counting movies in database"})
+				return
+			}
+
+			totalPages := (total + limit - 1) / limit
+			pagination.Page = page
+			pagination.Total = &total
+			pagination.TotalPages = totalPages
+			findOptions.SetSkip((page - 1) * limit)
+
+			links["first"] = utils.PageLink(_context, map[string]string{"page": "1"})
+			if page > 1 {
+				links["prev"] = utils.PageLink(_context, map[string]string{"page": strconv.FormatInt(page-1, 10)})
+			}
+			if totalPages > 0 {
+				links["last"] = utils.PageLink(_context, map[string]string{"page": strconv.FormatInt(totalPages, 10)})
+			}
+		}
+
+		cursor, err := movieCollection.Find(ctx, query, findOptions)
 		if err != nil {
 			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching movies from database"})
 			return
 		}
 		defer cursor.Close(ctx)
 
+		movies := []models.Movie{}
+
 		if err = cursor.All(ctx, &movies); err != nil {
 			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error decoding movies from database"})
 			return
 		}
 
-		_context.JSON(http.StatusOK, movies)
+		if int64(len(movies)) > limit {
+			movies = movies[:limit]
+			pagination.HasMore = true
+		}
+
+		if pagination.HasMore {
+			if pageParam != "" && cursorToken == "" {
+				links["next"] = utils.PageLink(_context, map[string]string{"page": strconv.FormatInt(pagination.Page+1, 10)})
+			} else {
+				last := movies[len(movies)-1]
+				nextCursor, err := utils.EncodeCursor(utils.PageCursor{
+					Sort:  sortKey,
+					Order: order,
+					Value: movieSortValue(last, sortKey),
+					ID:    last.ID.Hex(),
+				})
+				if err != nil {
+					_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error encoding pagination cursor"})
+					return
+				}
+
+				pagination.NextCursor = nextCursor
+				links["next"] = utils.PageLink(_context, map[string]string{"cursor": nextCursor, "page": ""})
+			}
+		}
+
+		utils.SetLinkHeader(_context, links)
+
+		_context.JSON(http.StatusOK, gin.H{
+			"data":       movies,
+			"pagination": pagination,
+		})
 	}
 }
 
@@ -56,12 +280,13 @@ func GetMovieByImdbID() gin.HandlerFunc {
 		imdbID := _context.Param("imdb_id")
 		var movie models.Movie
 
-		err := movieCollection.FindOne(ctx, bson.M{"imdb_id": imdbID}).Decode(&movie)
+		err := movieCollection.FindOne(ctx, utils.ActiveFilter(bson.M{"imdb_id": imdbID})).Decode(&movie)
 		if err != nil {
 			_context.JSON(http.StatusNotFound, gin.H{"error": "Movie not found"})
 			return
 		}
 
+		_context.Header("ETag", utils.VersionETag(movie.Version))
 		_context.JSON(http.StatusOK, movie)
 	}
 }
@@ -85,161 +310,371 @@ func AddMovie() gin.HandlerFunc {
 			return
 		}
 
+		genres, err := ResolveGenres(ctx, movie.Genre)
+		if err != nil {
+			respondGenreError(_context, err)
+			return
+		}
+		movie.Genre = genres
+
         if exists, err := utils.DocumentExists(ctx, movieCollection, bson.M{"imdb_id": movie.ImdbID}); err != nil {
             _context.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking for existing movie"})
             return
         } else if exists {
-            _context.JSON(http.StatusConflict, gin.H{"error": "Movie with this IMDB ID already exists"})
+            _context.JSON(http.StatusConflict, gin.H{"error": "Movie with this IMDB ID already exists (it may be in the trash)"})
             return
         }
 
+        movie.Version = 1
+        movie.RankingSource = nil
+        movie.UserRating = nil
+        movie.DeletedAt = nil
+        movie.DeletedBy = ""
+
         if err := utils.InsertDocument(ctx, movieCollection, movie); err != nil {
             _context.JSON(http.StatusInternalServerError, gin.H{"error": "Error inserting movie into database"})
             return
         }
 
+        _context.Header("ETag", utils.VersionETag(movie.Version))
         _context.JSON(http.StatusCreated, movie)
     }
 }
 
-var reviewUpdate struct {
-	AdminReview string `json:"admin_review" validate:"required"`
-}
-var reviewUpdateResponse struct {
-	RankingName string `json:"ranking_name"`
-	AdminReview string `json:"admin_review"`
+// Fields a merge patch may not touch: identity, the concurrency version, and
+// the review/ranking pair that only AdminReviewUpdate keeps consistent.
+var immutableMovieFields = []string{"_id", "imdb_id", "version", "admin_review", "ranking", "ranking_source", "user_rating"}
+
+// movieVersionFilter matches a movie at the given version. Movies inserted
+// before versioning have no version field and count as version 0.
+func movieVersionFilter(imdbID string, version int64) bson.M {
+	if version == 0 {
+		return utils.ActiveFilter(bson.M{"imdb_id": imdbID, "version": bson.M{"$in": bson.A{0, nil}}})
+	}
+
+	return utils.ActiveFilter(bson.M{"imdb_id": imdbID, "version": version})
 }
 
-func AdminReviewUpdate() gin.HandlerFunc {
+func UpdateMovie() gin.HandlerFunc {
 	return func(_context *gin.Context) {
-		role, err := utils.GetDataFromContext(_context, "role")
-		if err != nil || role != "ADMIN" {
-			_context.JSON(http.StatusForbidden, gin.H{"error": "Forbidden: Admins only"})
+		imdbID := _context.Param("imdb_id")
+
+		expectedVersion, present, ok := utils.IfMatchVersion(_context)
+		if !present {
+			_context.JSON(http.StatusPreconditionRequired, gin.H{"error": "If-Match header with the movie ETag is required"})
+			return
+		}
+		if !ok {
+			_context.JSON(http.StatusPreconditionFailed, gin.H{"error": "If-Match does not match the current movie version"})
 			return
 		}
 
-		imdbID := _context.Param("imdb_id")
-
-		if err := _context.BindJSON(&reviewUpdate); err != nil {
+		patch, err := _context.GetRawData()
+		if err != nil {
 			_context.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
 			return
 		}
 
-		sentiment, rankValue, err := GetReviewRanking(reviewUpdate.AdminReview)
+		var patchFields map[string]json.RawMessage
+		if err := json.Unmarshal(patch, &patchFields); err != nil {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": "Patch must be a JSON object"})
+			return
+		}
+
+		for _, field := range immutableMovieFields {
+			if _, exists := patchFields[field]; exists {
+				_context.JSON(http.StatusBadRequest, gin.H{"error": "Field " + field + " cannot be changed"})
+				return
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var movie models.Movie
+		err = movieCollection.FindOne(ctx, utils.ActiveFilter(bson.M{"imdb_id": imdbID})).Decode(&movie)
+		if err != nil {
+			_context.JSON(http.StatusNotFound, gin.H{"error": "Movie not found"})
+			return
+		}
+
+		if movie.Version != expectedVersion {
+			_context.Header("ETag", utils.VersionETag(movie.Version))
+			_context.JSON(http.StatusPreconditionFailed, gin.H{"error": "If-Match does not match the current movie version"})
+			return
+		}
+
+		current, err := json.Marshal(movie)
 		if err != nil {
-			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error getting review ranking"})
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error encoding movie"})
+			return
+		}
+
+		merged, err := utils.MergePatch(current, patch)
+		if err != nil {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		var updated models.Movie
+		if err := json.Unmarshal(merged, &updated); err != nil {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
 			return
 		}
-		log.Printf("Determined sentiment: %s with rank value: %d", sentiment, rankValue)
 
 		var validate = validator.New()
-		if err := validate.Struct(reviewUpdate); err != nil {
+		if err := validate.Struct(updated); err != nil {
 			_context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
 
+		updated.Genre, err = ResolveGenres(ctx, updated.Genre)
+		if err != nil {
+			respondGenreError(_context, err)
+			return
+		}
+
+		result, err := movieCollection.UpdateOne(
+			ctx,
+			movieVersionFilter(imdbID, expectedVersion),
+			bson.M{
+				"$set": bson.M{
+					"title":       updated.Title,
+					"poster_path": updated.PosterPath,
+					"youtube_id":  updated.YoutubeID,
+					"genre":       updated.Genre,
+					"version":     expectedVersion + 1,
+				},
+			},
+		)
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating movie"})
+			return
+		}
+		if result.MatchedCount == 0 {
+			_context.JSON(http.StatusPreconditionFailed, gin.H{"error": "Movie was modified concurrently, fetch it again and retry"})
+			return
+		}
+
+		updated.ID = movie.ID
+		updated.ImdbID = movie.ImdbID
+		updated.AdminReview = movie.AdminReview
+		updated.Ranking = movie.Ranking
+		updated.RankingStatus = movie.RankingStatus
+		updated.RankingSource = movie.RankingSource
+		updated.UserRating = movie.UserRating
+		updated.Version = expectedVersion + 1
+
+		_context.Header("ETag", utils.VersionETag(updated.Version))
+		_context.JSON(http.StatusOK, updated)
+	}
+}
+
+func DeleteMovie() gin.HandlerFunc {
+	return func(_context *gin.Context) {
+		imdbID := _context.Param("imdb_id")
+		filter := utils.ActiveFilter(bson.M{"imdb_id": imdbID})
+
+		userId, err := utils.GetDataFromContext(_context, "userId")
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving user data from context"})
+			return
+		}
+
+		expectedVersion, present, ok := utils.IfMatchVersion(_context)
+		if present && !ok {
+			_context.JSON(http.StatusPreconditionFailed, gin.H{"error": "If-Match does not match the current movie version"})
+			return
+		}
+		if present {
+			filter = movieVersionFilter(imdbID, expectedVersion)
+		}
+
 		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
 
-		exists, err := utils.DocumentExists(ctx, movieCollection, bson.M{"imdb_id": imdbID})
-		
+		result, err := movieCollection.UpdateOne(
+			ctx,
+			filter,
+			bson.M{
+				"$set": bson.M{"deleted_at": time.Now(), "deleted_by": userId},
+				"$inc": bson.M{"version": 1},
+			},
+		)
 		if err != nil {
-			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking for existing movie"})
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error deleting movie"})
 			return
 		}
-		if !exists {
+
+		if result.MatchedCount == 0 {
+			if exists, err := utils.DocumentExists(ctx, movieCollection, utils.ActiveFilter(bson.M{"imdb_id": imdbID})); err == nil && exists {
+				_context.JSON(http.StatusPreconditionFailed, gin.H{"error": "If-Match does not match the current movie version"})
+				return
+			}
+
 			_context.JSON(http.StatusNotFound, gin.H{"error": "Movie not found"})
 			return
 		}
 
-		err = utils.UpdateDocument(
-			ctx,
-			movieCollection,
-			bson.M{"imdb_id": imdbID},
-			bson.M{"$set": bson.M{
-				"admin_review": reviewUpdate.AdminReview, 
-				"ranking": bson.M{
-					"ranking_name": sentiment,
-					"ranking_value": rankValue,
-				},
-			}},
-		)
+		_context.JSON(http.StatusOK, gin.H{"message": "Movie moved to trash"})
+	}
+}
+
+type reviewUpdate struct {
+	AdminReview string `json:"admin_review" validate:"required"`
+}
+
+// AdminReviewUpdate saves the review straight away with the default ranking
+// and a pending ranking status, records it in the review history, and queues
+// a job for the review workers to rank it.
+func AdminReviewUpdate() gin.HandlerFunc {
+	return func(_context *gin.Context) {
+		imdbID := _context.Param("imdb_id")
 
+		userId, err := utils.GetDataFromContext(_context, "userId")
 		if err != nil {
-			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating admin review"})
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving user data from context"})
 			return
 		}
+		email, _ := utils.GetDataFromContext(_context, "email")
 
-		_context.JSON(http.StatusOK, gin.H{
-			"message": "Admin review updated successfully",
-			"ranking_name": sentiment,
-			"admin_review": reviewUpdate.AdminReview,
+		var update reviewUpdate
+		if err := _context.BindJSON(&update); err != nil {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+
+		var validate = validator.New()
+		if err := validate.Struct(update); err != nil {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		entry, err := saveAdminReview(ctx, models.ReviewHistoryEntry{
+			ImdbID:      imdbID,
+			Action:      models.ReviewActionUpdate,
+			AuthorID:    userId,
+			AuthorEmail: email,
+			NewReview:   update.AdminReview,
 		})
+		if err != nil {
+			respondReviewSaveError(_context, err)
+			return
+		}
+
+		respondReviewSaved(_context, entry)
 	}
 }
 
-func GetReviewRanking(admin_review string) (string, int, error) {
-	rankings, err := GetRankings()
-	if err != nil {
-		return "", 0, err
-	}
+var reviewRanker llm.ReviewRanker
+var reviewRankerMutex sync.Mutex
 
-	sentimentDelimited := ""
+// SetReviewRanker replaces the ranker selected from the environment, for
+// example with an llm.FakeProvider in tests.
+func SetReviewRanker(ranker llm.ReviewRanker) {
+	reviewRankerMutex.Lock()
+	defer reviewRankerMutex.Unlock()
 
-	for _, ranking := range rankings {
-		if ranking.RankingValue != 999 {
-			sentimentDelimited += ranking.RankingName + ","
+	reviewRanker = ranker
+}
+
+func getReviewRanker() (llm.ReviewRanker, error) {
+	reviewRankerMutex.Lock()
+	defer reviewRankerMutex.Unlock()
+
+	if reviewRanker == nil {
+		ranker, err := llm.NewReviewRankerFromEnv()
+		if err != nil {
+			return nil, err
 		}
+		reviewRanker = ranker
 	}
 
-	sentimentDelimited = strings.Trim(sentimentDelimited, ",")
+	return reviewRanker, nil
+}
 
-	err = godotenv.Load()
+// GetReviewRanking ranks a movie's admin review with the active prompt
+// template, on behalf of userId for the usage report.
+func GetReviewRanking(movie models.Movie, userId string) (llm.RankingResult, error) {
+	rankings, err := GetRankings()
 	if err != nil {
-		log.Println("Warning: .env file not found, proceeding with existing environment variables")
-		return "", 0, err
+		return llm.RankingResult{}, err
 	}
 
-	OpenAIKey := os.Getenv("OPENAI_API_KEY")
-	
-	if OpenAIKey == "" {
-		return "", 0, errors.New("OPENAI_API_KEY not set in environment")
+	ranker, err := getReviewRanker()
+	if err != nil {
+		return llm.RankingResult{}, err
 	}
 
-	llm, err := openai.New(openai.WithToken(OpenAIKey))
+	ctx, cancel := context.WithTimeout(context.Background(), llmTotalTimeout())
+	defer cancel()
+
+	prompt, err := GetActivePromptTemplate(ctx)
 	if err != nil {
-		return "", 0, err
+		return llm.RankingResult{}, err
 	}
 
-	prompt := strings.Replace(os.Getenv("BASE_PROMPT_TEMPLATE"), "{rankings}", sentimentDelimited, 1)
+	genres := make([]string, 0, len(movie.Genre))
+	for _, genre := range movie.Genre {
+		genres = append(genres, genre.GenreName)
+	}
 
-	response, err := llm.Call(context.Background(), prompt + admin_review)
-	if err != nil {
-		return "", 0, err
+	input := llm.ReviewInput{
+		Title:  movie.Title,
+		Genres: genres,
+		Review: movie.AdminReview,
+		Prompt: prompt,
 	}
-	rankVal := 0
 
-	for _, ranking := range rankings {
-		if strings.EqualFold(strings.TrimSpace(response), ranking.RankingName) {
-			rankVal = ranking.RankingValue
-			break
-		}
+	return rankReviewWithAccounting(ctx, ranker, input, rankings, movie.ImdbID, userId)
+}
+
+func rankingSource(result llm.RankingResult) models.RankingSource {
+	return models.RankingSource{
+		Provider:      result.Provider,
+		Model:         result.Model,
+		PromptVersion: result.PromptVersion,
+		RankedAt:      time.Now(),
+	}
+}
+
+// llmTotalTimeout bounds a whole ranking, retries and backoff included.
+func llmTotalTimeout() time.Duration {
+	seconds := 90
+
+	if value, err := strconv.Atoi(os.Getenv("LLM_TOTAL_TIMEOUT_SECONDS")); err == nil && value > 0 {
+		seconds = value
 	}
 
-	if rankVal == 0 {
-		return "", 0, errors.New("could not determine ranking from response")
+	return time.Duration(seconds) * time.Second
+}
+
+// GetDefaultRanking returns the ranking flagged is_default, the one given to
+// movies that have not been ranked.
+func GetDefaultRanking() (models.Ranking, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	var ranking models.RankingDefinition
+	if err := rankingCollection.FindOne(ctx, bson.M{"is_default": true}).Decode(&ranking); err != nil {
+		return models.Ranking{}, err
 	}
 
-	return response, rankVal, nil
+	return ranking.Ranking(), nil
 }
 
-func GetRankings() ([]models.Ranking, error) {
-	var rankings []models.Ranking
+func GetRankings() ([]models.RankingDefinition, error) {
+	var rankings []models.RankingDefinition
 
 	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
 	defer cancel()
 
-	cursor, err := rankingCollection.Find(ctx, bson.M{})
+	findOptions := options.Find().SetSort(bson.D{{Key: "order", Value: 1}, {Key: "ranking_value", Value: 1}})
+
+	cursor, err := rankingCollection.Find(ctx, bson.M{}, findOptions)
 	if err != nil {
 		return rankings, err
 	}
@@ -285,10 +720,19 @@ func GetRecommendedMovies() gin.HandlerFunc {
 		findOptions.SetSort(bson.D{{Key: "ranking.ranking_value", Value: 1}})
 		findOptions.SetLimit(recommendedMovieLimit)
 		
-		movieFilter := bson.M{"genre.genre_name": bson.M{"$in": favouriteGenres}}
+		movieFilter := utils.ActiveFilter(bson.M{"genre.genre_name": bson.M{"$in": favouriteGenres}})
 
 		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
+
+		watchlisted, err := GetWatchlistImdbIDs(ctx, userId)
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching watchlist"})
+			return
+		}
+		if len(watchlisted) > 0 {
+			movieFilter["imdb_id"] = bson.M{"$nin": watchlisted}
+		}
 		
 		cursor, err := movieCollection.Find(ctx, movieFilter, findOptions)
 		if err != nil {
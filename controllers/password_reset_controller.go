@@ -0,0 +1,251 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	db "github.com/Neph-dev/MovieStreamServer/database"
+	"github.com/Neph-dev/MovieStreamServer/mailer"
+	"github.com/Neph-dev/MovieStreamServer/models"
+	"github.com/Neph-dev/MovieStreamServer/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+var passwordResetCollection *mongo.Collection = db.OpenCollection("password_resets")
+
+const passwordResetInvalid = "Reset token is invalid or has expired"
+
+var passwordResetIndexesOnce sync.Once
+
+func ensurePasswordResetIndexes(ctx context.Context) {
+	passwordResetIndexesOnce.Do(func() {
+		_, err := passwordResetCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+			{Keys: bson.D{{Key: "token_hash", Value: 1}}, Options: options.Index().SetUnique(true)},
+			{Keys: bson.D{{Key: "user_id", Value: 1}}},
+			{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+		})
+		if err != nil {
+			log.Println("Warning: could not create password reset indexes:", err)
+		}
+	})
+}
+
+func passwordResetTokenTTL() time.Duration {
+	return time.Duration(utils.GetEnvInt("PASSWORD_RESET_TOKEN_TTL_MINUTES", 30)) * time.Minute
+}
+
+// allowPasswordResetRequest applies the per-email and per-IP limits. The
+// email limit counts every address alike, so it reveals nothing about which
+// ones have accounts.
+func allowPasswordResetRequest(ctx context.Context, action string, email string, ip string) (bool, error) {
+	window := time.Duration(utils.GetEnvInt("PASSWORD_RESET_WINDOW_MINUTES", 60)) * time.Minute
+
+	allowed, err := utils.AllowRate(ctx, action+":email:"+strings.ToLower(email), utils.GetEnvInt("PASSWORD_RESET_EMAIL_LIMIT", 5), window)
+	if err != nil || !allowed {
+		return false, err
+	}
+
+	return utils.AllowRate(ctx, action+":ip:"+ip, utils.GetEnvInt("PASSWORD_RESET_IP_LIMIT", 20), window)
+}
+
+// issuePasswordReset replaces any outstanding reset token of the account with
+// a new one and mails it. Unknown addresses are silently ignored.
+func issuePasswordReset(email string, ip string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	var user models.User
+	err := userCollection.FindOne(ctx, utils.ActiveFilter(bson.M{"email": email})).Decode(&user)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return
+	}
+	if err != nil {
+		log.Println("Error looking up user for password reset:", err)
+		return
+	}
+
+	token, hash, err := utils.NewSecretToken()
+	if err != nil {
+		log.Println("Error generating password reset token:", err)
+		return
+	}
+
+	if _, err := passwordResetCollection.DeleteMany(ctx, bson.M{"user_id": user.UserID}); err != nil {
+		log.Println("Error clearing password reset tokens for user", user.UserID+":", err)
+		return
+	}
+
+	now := time.Now()
+	_, err = passwordResetCollection.InsertOne(ctx, models.PasswordReset{
+		TokenHash:   hash,
+		UserID:      user.UserID,
+		RequestedIP: ip,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(passwordResetTokenTTL()),
+	})
+	if err != nil {
+		log.Println("Error saving password reset token for user", user.UserID+":", err)
+		return
+	}
+
+	err = sendMail(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your MovieStream password",
+		Body: "Hi " + user.FirstName + ",\n\n" +
+			"Someone asked to reset the password of your MovieStream account. To choose a new password, open:\n\n" +
+			tokenInstructions("/reset-password", token) + "\n" +
+			"This expires in " + passwordResetTokenTTL().String() + " and can be used once. If you did not ask for it, ignore this email.\n",
+	})
+	if err != nil {
+		log.Println("Error sending password reset email to user", user.UserID+":", err)
+	}
+}
+
+// ForgotPassword mails a reset token to the account with the given email.
+// The answer is the same whether or not the account exists, and the work is
+// done in the background so the response time does not tell either.
+func ForgotPassword() gin.HandlerFunc {
+	return func(_context *gin.Context) {
+		var request models.ForgotPasswordRequest
+		if err := _context.BindJSON(&request); err != nil {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+
+		var validate = validator.New()
+		if err := validate.Struct(request); err != nil {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "details": err.Error()})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		ensurePasswordResetIndexes(ctx)
+
+		ip := _context.ClientIP()
+		allowed, err := allowPasswordResetRequest(ctx, "password_forgot", request.Email, ip)
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking rate limit"})
+			return
+		}
+		if !allowed {
+			_context.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many password reset requests, try again later"})
+			return
+		}
+
+		go issuePasswordReset(request.Email, ip)
+
+		_context.JSON(http.StatusAccepted, gin.H{"message": "If an account exists for this email, a password reset link has been sent"})
+	}
+}
+
+// ResetPassword sets a new password with a token from ForgotPassword. The
+// token is spent on success, and every session of the account is ended.
+func ResetPassword() gin.HandlerFunc {
+	return func(_context *gin.Context) {
+		var request models.ResetPasswordRequest
+		if err := _context.BindJSON(&request); err != nil {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+
+		var validate = validator.New()
+		if err := validate.Struct(request); err != nil {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "details": err.Error()})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		allowed, err := allowPasswordResetRequest(ctx, "password_reset", request.Email, _context.ClientIP())
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking rate limit"})
+			return
+		}
+		if !allowed {
+			_context.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many password reset attempts, try again later"})
+			return
+		}
+
+		hash := utils.HashSecretToken(request.Token)
+		unused := bson.M{"token_hash": hash, "used_at": nil, "expires_at": bson.M{"$gt": time.Now()}}
+
+		var reset models.PasswordReset
+		err = passwordResetCollection.FindOne(ctx, unused).Decode(&reset)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": passwordResetInvalid})
+			return
+		}
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking reset token"})
+			return
+		}
+
+		// A token only works together with the address it was sent to.
+		var user models.User
+		err = userCollection.FindOne(ctx, utils.ActiveFilter(bson.M{"user_id": reset.UserID})).Decode(&user)
+		if errors.Is(err, mongo.ErrNoDocuments) || (err == nil && !strings.EqualFold(user.Email, request.Email)) {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": passwordResetInvalid})
+			return
+		}
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching user"})
+			return
+		}
+
+		hashedPassword, err := HashPassword(request.NewPassword)
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error hashing password"})
+			return
+		}
+
+		// Spending the token first means two concurrent resets cannot both
+		// succeed with it.
+		result, err := passwordResetCollection.UpdateOne(ctx, unused, bson.M{"$set": bson.M{"used_at": time.Now()}})
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error using reset token"})
+			return
+		}
+		if result.MatchedCount == 0 {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": passwordResetInvalid})
+			return
+		}
+
+		// The token was mailed to the account's address, so using it also
+		// verifies that address.
+		_, err = userCollection.UpdateOne(
+			ctx,
+			bson.M{"user_id": user.UserID},
+			bson.M{
+				"$set": bson.M{"password": hashedPassword, "email_verified": true, "updated_at": time.Now()},
+				"$min": bson.M{"email_verified_at": time.Now()},
+			},
+		)
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating password"})
+			return
+		}
+
+		if err := utils.RevokeAllUserTokens(user.UserID); err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error revoking tokens"})
+			return
+		}
+
+		if _, err := passwordResetCollection.DeleteMany(ctx, bson.M{"user_id": user.UserID}); err != nil {
+			log.Println("Error clearing password reset tokens for user", user.UserID+":", err)
+		}
+
+		_context.JSON(http.StatusOK, gin.H{"message": "Password has been reset, please log in again"})
+	}
+}
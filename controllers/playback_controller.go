@@ -0,0 +1,416 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	db "github.com/Neph-dev/MovieStreamServer/database"
+	"github.com/Neph-dev/MovieStreamServer/models"
+	"github.com/Neph-dev/MovieStreamServer/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+var playbackEventCollection *mongo.Collection = db.OpenCollection("playback_events")
+var watchProgressCollection *mongo.Collection = db.OpenCollection("watch_progress")
+
+const (
+	playbackMaxBatch = 100
+	// Client clocks drift; events further ahead than this are rejected.
+	playbackMaxClockSkew = 5 * time.Minute
+)
+
+var playbackIndexesOnce sync.Once
+
+func ensurePlaybackIndexes(ctx context.Context) {
+	playbackIndexesOnce.Do(func() {
+		_, err := playbackEventCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+			{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "event_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+			{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+		})
+		if err == nil {
+			_, err = watchProgressCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+				{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "imdb_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+				{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "last_watched_at", Value: -1}}},
+			})
+		}
+		if err != nil {
+			log.Println("Warning: could not create playback indexes:", err)
+		}
+	})
+}
+
+// playbackEventRetention is how long event ids are remembered for
+// de-duplication.
+func playbackEventRetention() time.Duration {
+	return time.Duration(utils.GetEnvInt("PLAYBACK_EVENT_RETENTION_DAYS", 30)) * 24 * time.Hour
+}
+
+// playbackWatchedRatio is the share of a movie that counts as having
+// watched it, for clients that stop before sending a finish event.
+func playbackWatchedRatio() float64 {
+	if ratio := envFloat("PLAYBACK_WATCHED_RATIO"); ratio > 0 && ratio <= 1 {
+		return ratio
+	}
+
+	return 0.9
+}
+
+func playbackResumeMinSeconds() int {
+	return utils.GetEnvInt("PLAYBACK_RESUME_MIN_SECONDS", 30)
+}
+
+// recordPlaybackEvent stores the event unless the user already sent one with
+// the same event_id, in which case the stored event is returned instead so a
+// reused id can never apply different data.
+func recordPlaybackEvent(ctx context.Context, event models.PlaybackEvent) (models.PlaybackEvent, bool, error) {
+	_, err := playbackEventCollection.InsertOne(ctx, event)
+	if err == nil {
+		return event, false, nil
+	}
+	if !mongo.IsDuplicateKeyError(err) {
+		return event, false, err
+	}
+
+	var stored models.PlaybackEvent
+	err = playbackEventCollection.FindOne(ctx, bson.M{"user_id": event.UserID, "event_id": event.EventID}).Decode(&stored)
+	return stored, true, err
+}
+
+// applyPlaybackEvent folds an event into the user's progress for the movie.
+// Every update is idempotent and ordered by occurred_at rather than arrival,
+// so events may be replayed or delivered in any order.
+func applyPlaybackEvent(ctx context.Context, event models.PlaybackEvent) error {
+	filter := bson.M{"user_id": event.UserID, "imdb_id": event.ImdbID}
+
+	position := event.PositionSeconds
+	finished := event.Type == models.PlaybackFinish
+	if event.DurationSeconds > 0 && position >= event.DurationSeconds*playbackWatchedRatio() {
+		finished = true
+	}
+	if finished {
+		position = max(position, event.DurationSeconds)
+	}
+
+	update := bson.M{
+		"$setOnInsert": bson.M{"position_seconds": 0.0, "position_at": time.Time{}},
+		"$min":         bson.M{"started_at": event.OccurredAt},
+		"$max":         bson.M{"last_watched_at": event.OccurredAt, "duration_seconds": event.DurationSeconds},
+	}
+	if finished {
+		update["$set"] = bson.M{"watched": true}
+		update["$max"].(bson.M)["finished_at"] = event.OccurredAt
+	} else {
+		update["$setOnInsert"].(bson.M)["watched"] = false
+	}
+
+	_, err := watchProgressCollection.UpdateOne(ctx, filter, update, options.UpdateOne().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		// Lost an upsert race with another event for the same movie; the
+		// document exists now, so the update applies on the second try.
+		_, err = watchProgressCollection.UpdateOne(ctx, filter, update)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Only an event newer than the stored position may replace it, so a
+	// late heartbeat cannot rewind the resume point.
+	positionFilter := bson.M{
+		"user_id":     event.UserID,
+		"imdb_id":     event.ImdbID,
+		"position_at": bson.M{"$lt": event.OccurredAt},
+	}
+	_, err = watchProgressCollection.UpdateOne(ctx, positionFilter, bson.M{
+		"$set": bson.M{"position_seconds": position, "position_at": event.OccurredAt},
+	})
+	return err
+}
+
+type playbackEventResult struct {
+	EventID string `json:"event_id"`
+	Status  string `json:"status"`
+	Error   string `json:"error,omitempty"`
+}
+
+// PostPlaybackEvents accepts a batch of start, heartbeat and finish events
+// from a player. Each event gets its own result: applied, duplicate (already
+// received), invalid or error; events that errored can be resent as is.
+func PostPlaybackEvents() gin.HandlerFunc {
+	return func(_context *gin.Context) {
+		userId, err := utils.GetDataFromContext(_context, "userId")
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving user data from context"})
+			return
+		}
+
+		var request struct {
+			Events []models.PlaybackEvent `json:"events"`
+		}
+		if err := _context.BindJSON(&request); err != nil {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+		if len(request.Events) == 0 || len(request.Events) > playbackMaxBatch {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": "events must contain between 1 and " + strconv.Itoa(playbackMaxBatch) + " events"})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		ensurePlaybackIndexes(ctx)
+
+		var validate = validator.New()
+		now := time.Now()
+		results := make([]playbackEventResult, len(request.Events))
+		valid := make([]int, 0, len(request.Events))
+		imdbIDs := []string{}
+
+		for i, event := range request.Events {
+			results[i] = playbackEventResult{EventID: event.EventID, Status: "invalid"}
+			if err := validate.Struct(event); err != nil {
+				results[i].Error = err.Error()
+				continue
+			}
+			if event.OccurredAt.IsZero() {
+				results[i].Error = "occurred_at is required"
+				continue
+			}
+			if event.OccurredAt.After(now.Add(playbackMaxClockSkew)) {
+				results[i].Error = "occurred_at is in the future"
+				continue
+			}
+			valid = append(valid, i)
+			if !slices.Contains(imdbIDs, event.ImdbID) {
+				imdbIDs = append(imdbIDs, event.ImdbID)
+			}
+		}
+
+		known := map[string]bool{}
+		if len(imdbIDs) > 0 {
+			var found []string
+			err := movieCollection.Distinct(ctx, "imdb_id", utils.ActiveFilter(bson.M{"imdb_id": bson.M{"$in": imdbIDs}})).Decode(&found)
+			if err != nil {
+				_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking movies"})
+				return
+			}
+			for _, imdbID := range found {
+				known[imdbID] = true
+			}
+		}
+
+		for _, i := range valid {
+			event := request.Events[i]
+			if !known[event.ImdbID] {
+				results[i].Error = "Movie not found"
+				continue
+			}
+
+			event.UserID = userId
+			event.OccurredAt = event.OccurredAt.UTC()
+			event.ReceivedAt = now
+			event.ExpiresAt = now.Add(playbackEventRetention())
+
+			stored, duplicate, err := recordPlaybackEvent(ctx, event)
+			if err == nil {
+				// Replaying a duplicate is harmless and completes any event
+				// whose progress update failed on an earlier attempt.
+				err = applyPlaybackEvent(ctx, stored)
+			}
+			if err != nil {
+				log.Println("Error applying playback event", event.EventID, "for user", userId+":", err)
+				results[i].Status = "error"
+				results[i].Error = "Error recording event, retry"
+				continue
+			}
+
+			results[i].Status = "applied"
+			if duplicate {
+				results[i].Status = "duplicate"
+			}
+		}
+
+		_context.JSON(http.StatusOK, gin.H{"results": results})
+	}
+}
+
+// attachProgressMovies embeds each entry's movie; removed movies are left
+// without one.
+func attachProgressMovies(ctx context.Context, progress []models.WatchProgress) error {
+	if len(progress) == 0 {
+		return nil
+	}
+
+	imdbIDs := make([]string, 0, len(progress))
+	for _, entry := range progress {
+		imdbIDs = append(imdbIDs, entry.ImdbID)
+	}
+
+	cursor, err := movieCollection.Find(ctx, utils.ActiveFilter(bson.M{"imdb_id": bson.M{"$in": imdbIDs}}))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	var found []models.Movie
+	if err := cursor.All(ctx, &found); err != nil {
+		return err
+	}
+
+	movies := map[string]models.Movie{}
+	for _, movie := range found {
+		movies[movie.ImdbID] = movie
+	}
+	for i := range progress {
+		if movie, ok := movies[progress[i].ImdbID]; ok {
+			progress[i].Movie = &movie
+		}
+	}
+
+	return nil
+}
+
+// GetWatchHistory lists every movie the current user has played, most
+// recently watched first. ?watched=true or false narrows it to finished or
+// unfinished movies.
+func GetWatchHistory() gin.HandlerFunc {
+	return func(_context *gin.Context) {
+		userId, err := utils.GetDataFromContext(_context, "userId")
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving user data from context"})
+			return
+		}
+
+		limit, err := utils.GetLimitParam(_context, 20, 100)
+		if err != nil {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		page, err := strconv.ParseInt(_context.DefaultQuery("page", "1"), 10, 64)
+		if err != nil || page < 1 {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": "page must be a positive integer"})
+			return
+		}
+
+		filter := bson.M{"user_id": userId}
+		if value := _context.Query("watched"); value != "" {
+			watched, err := strconv.ParseBool(value)
+			if err != nil {
+				_context.JSON(http.StatusBadRequest, gin.H{"error": "watched must be true or false"})
+				return
+			}
+			filter["watched"] = watched
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		total, err := watchProgressCollection.CountDocuments(ctx, filter)
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error counting watch history"})
+			return
+		}
+
+		findOptions := options.Find().
+			SetSort(bson.D{{Key: "last_watched_at", Value: -1}, {Key: "_id", Value: -1}}).
+			SetSkip((page - 1) * limit).
+			SetLimit(limit)
+
+		cursor, err := watchProgressCollection.Find(ctx, filter, findOptions)
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching watch history"})
+			return
+		}
+		defer cursor.Close(ctx)
+
+		history := []models.WatchProgress{}
+		if err = cursor.All(ctx, &history); err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error decoding watch history"})
+			return
+		}
+
+		if err := attachProgressMovies(ctx, history); err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching watch history movies"})
+			return
+		}
+
+		_context.JSON(http.StatusOK, gin.H{
+			"data": history,
+			"pagination": utils.Pagination{
+				Limit:      limit,
+				Page:       page,
+				Total:      &total,
+				TotalPages: (total + limit - 1) / limit,
+				HasMore:    page*limit < total,
+			},
+		})
+	}
+}
+
+// GetContinueWatching lists the movies the current user can resume: played
+// past the first few seconds and not finished since, most recent first.
+// A movie finished earlier but started again is listed with its new position.
+func GetContinueWatching() gin.HandlerFunc {
+	return func(_context *gin.Context) {
+		userId, err := utils.GetDataFromContext(_context, "userId")
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving user data from context"})
+			return
+		}
+
+		limit, err := utils.GetLimitParam(_context, 20, 50)
+		if err != nil {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		filter := bson.M{
+			"user_id":          userId,
+			"position_seconds": bson.M{"$gte": playbackResumeMinSeconds()},
+			"$expr": bson.M{"$or": bson.A{
+				bson.M{"$eq": bson.A{bson.M{"$ifNull": bson.A{"$finished_at", nil}}, nil}},
+				bson.M{"$gt": bson.A{"$position_at", "$finished_at"}},
+			}},
+		}
+
+		findOptions := options.Find().
+			SetSort(bson.D{{Key: "last_watched_at", Value: -1}, {Key: "_id", Value: -1}}).
+			SetLimit(limit)
+
+		cursor, err := watchProgressCollection.Find(ctx, filter, findOptions)
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching continue watching"})
+			return
+		}
+		defer cursor.Close(ctx)
+
+		progress := []models.WatchProgress{}
+		if err = cursor.All(ctx, &progress); err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error decoding continue watching"})
+			return
+		}
+
+		if err := attachProgressMovies(ctx, progress); err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching continue watching movies"})
+			return
+		}
+
+		// Movies removed from the catalogue cannot be resumed.
+		progress = slices.DeleteFunc(progress, func(entry models.WatchProgress) bool { return entry.Movie == nil })
+
+		_context.JSON(http.StatusOK, gin.H{"data": progress, "count": len(progress)})
+	}
+}
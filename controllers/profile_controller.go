@@ -0,0 +1,386 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Neph-dev/MovieStreamServer/mailer"
+	"github.com/Neph-dev/MovieStreamServer/models"
+	"github.com/Neph-dev/MovieStreamServer/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func emailChangeTokenTTL() time.Duration {
+	return time.Duration(utils.GetEnvInt("EMAIL_CHANGE_TOKEN_TTL_HOURS", 24)) * time.Hour
+}
+
+func toUserResponse(user models.User) models.UserResponse {
+	genres := user.FavouriteGenres
+	if genres == nil {
+		genres = []models.Genre{}
+	}
+
+	return models.UserResponse{
+		UserID:          user.UserID,
+		FirstName:       user.FirstName,
+		LastName:        user.LastName,
+		Email:           user.Email,
+		EmailVerified:   user.EmailVerified,
+		PendingEmail:    user.PendingEmail,
+		Role:            user.Role,
+		FavouriteGenres: genres,
+		CreatedAt:       user.CreatedAt,
+		UpdatedAt:       user.UpdatedAt,
+		DeletedAt:       user.DeletedAt,
+	}
+}
+
+func getCurrentUser(ctx context.Context, _context *gin.Context) (models.User, bool) {
+	var user models.User
+
+	userId, err := utils.GetDataFromContext(_context, "userId")
+	if err != nil {
+		_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving user data from context"})
+		return user, false
+	}
+
+	err = userCollection.FindOne(ctx, utils.ActiveFilter(bson.M{"user_id": userId})).Decode(&user)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		_context.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return user, false
+	}
+	if err != nil {
+		_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching user"})
+		return user, false
+	}
+
+	return user, true
+}
+
+// sendEmailChangeConfirmation mails the confirmation token to the new
+// address, which proves the user can receive mail there.
+func sendEmailChangeConfirmation(ctx context.Context, user models.User, email string, token string) error {
+	return sendMail(ctx, mailer.Message{
+		To:      email,
+		Subject: "Confirm your new MovieStream email",
+		Body: "Hi " + user.FirstName + ",\n\n" +
+			"To use this address for your MovieStream account, open:\n\n" +
+			tokenInstructions("/confirm-email", token) + "\n" +
+			"This expires in " + emailChangeTokenTTL().String() + ". If you did not ask for it, ignore this email.\n",
+	})
+}
+
+func GetProfile() gin.HandlerFunc {
+	return func(_context *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		user, ok := getCurrentUser(ctx, _context)
+		if !ok {
+			return
+		}
+
+		_context.JSON(http.StatusOK, toUserResponse(user))
+	}
+}
+
+// UpdateProfile changes the current user's name and favourite genres. The
+// email and password have their own endpoints.
+func UpdateProfile() gin.HandlerFunc {
+	return func(_context *gin.Context) {
+		var raw map[string]any
+		if err := _context.ShouldBindBodyWithJSON(&raw); err != nil {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+		if _, ok := raw["email"]; ok {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": "email cannot be changed here, use POST /me/email"})
+			return
+		}
+		if _, ok := raw["password"]; ok {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": "password cannot be changed here, use POST /me/password"})
+			return
+		}
+
+		var update models.ProfileUpdate
+		if err := _context.ShouldBindBodyWithJSON(&update); err != nil {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+
+		var validate = validator.New()
+		if err := validate.Struct(update); err != nil {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "details": err.Error()})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		user, ok := getCurrentUser(ctx, _context)
+		if !ok {
+			return
+		}
+
+		fields := bson.M{"updated_at": time.Now()}
+		if update.FirstName != nil {
+			fields["first_name"] = strings.TrimSpace(*update.FirstName)
+		}
+		if update.LastName != nil {
+			fields["last_name"] = strings.TrimSpace(*update.LastName)
+		}
+		if update.FavouriteGenres != nil {
+			genres, err := ResolveGenres(ctx, *update.FavouriteGenres)
+			if err != nil {
+				respondGenreError(_context, err)
+				return
+			}
+			if genres == nil {
+				genres = []models.Genre{}
+			}
+			fields["favourite_genres"] = genres
+		}
+
+		opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+		err := userCollection.FindOneAndUpdate(ctx, utils.ActiveFilter(bson.M{"user_id": user.UserID}), bson.M{"$set": fields}, opts).Decode(&user)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			_context.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating profile"})
+			return
+		}
+
+		_context.JSON(http.StatusOK, toUserResponse(user))
+	}
+}
+
+// RequestEmailChange starts an email change. The address only changes once
+// the token sent to the new address is confirmed; a newer request replaces
+// any pending one.
+func RequestEmailChange() gin.HandlerFunc {
+	return func(_context *gin.Context) {
+		var request models.EmailChangeRequest
+		if err := _context.BindJSON(&request); err != nil {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+
+		var validate = validator.New()
+		if err := validate.Struct(request); err != nil {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "details": err.Error()})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		user, ok := getCurrentUser(ctx, _context)
+		if !ok {
+			return
+		}
+
+		if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(request.Password)); err != nil {
+			_context.JSON(http.StatusUnauthorized, gin.H{"error": "Password is incorrect"})
+			return
+		}
+
+		if request.NewEmail == user.Email {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": "New email is the same as the current one"})
+			return
+		}
+
+		if exists, err := utils.DocumentExists(ctx, userCollection, bson.M{"email": request.NewEmail}); err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking for existing user"})
+			return
+		} else if exists {
+			_context.JSON(http.StatusConflict, gin.H{"error": "User with this email already exists"})
+			return
+		}
+
+		token, hash, err := utils.NewSecretToken()
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error generating confirmation token"})
+			return
+		}
+
+		expiresAt := time.Now().Add(emailChangeTokenTTL())
+		_, err = userCollection.UpdateOne(ctx, bson.M{"user_id": user.UserID}, bson.M{"$set": bson.M{
+			"pending_email":           request.NewEmail,
+			"email_change_token_hash": hash,
+			"email_change_expires_at": expiresAt,
+			"updated_at":              time.Now(),
+		}})
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error saving email change"})
+			return
+		}
+
+		if err := sendEmailChangeConfirmation(ctx, user, request.NewEmail, token); err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error sending confirmation email"})
+			return
+		}
+
+		_context.JSON(http.StatusAccepted, gin.H{
+			"message":       "Confirmation sent to the new email address",
+			"pending_email": request.NewEmail,
+			"expires_at":    expiresAt,
+		})
+	}
+}
+
+// ConfirmEmailChange applies a pending email change. It is unprotected so the
+// link works from any device; the token identifies the user. Receiving the
+// token proves the new address, so it counts as verified. Sessions carry the
+// email, so all of them are ended.
+func ConfirmEmailChange() gin.HandlerFunc {
+	return func(_context *gin.Context) {
+		var request models.EmailChangeConfirmation
+		if err := _context.BindJSON(&request); err != nil {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+
+		var validate = validator.New()
+		if err := validate.Struct(request); err != nil {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "details": err.Error()})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		hash := utils.HashSecretToken(request.Token)
+
+		var user models.User
+		err := userCollection.FindOne(ctx, utils.ActiveFilter(bson.M{
+			"email_change_token_hash": hash,
+			"email_change_expires_at": bson.M{"$gt": time.Now()},
+		})).Decode(&user)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": "Confirmation token is invalid or has expired"})
+			return
+		}
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching user"})
+			return
+		}
+
+		if exists, err := utils.DocumentExists(ctx, userCollection, bson.M{"email": user.PendingEmail}); err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking for existing user"})
+			return
+		} else if exists {
+			_context.JSON(http.StatusConflict, gin.H{"error": "User with this email already exists"})
+			return
+		}
+
+		// Matching the hash makes the token single-use even if it is
+		// confirmed twice at once.
+		result, err := userCollection.UpdateOne(
+			ctx,
+			bson.M{"user_id": user.UserID, "email_change_token_hash": hash},
+			bson.M{
+				"$set":   bson.M{"email": user.PendingEmail, "email_verified": true, "email_verified_at": time.Now(), "updated_at": time.Now()},
+				"$unset": bson.M{"pending_email": "", "email_change_token_hash": "", "email_change_expires_at": ""},
+			},
+		)
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating email"})
+			return
+		}
+		if result.MatchedCount == 0 {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": "Confirmation token is invalid or has expired"})
+			return
+		}
+
+		if err := utils.RevokeAllUserTokens(user.UserID); err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error revoking tokens"})
+			return
+		}
+
+		_context.JSON(http.StatusOK, gin.H{"message": "Email changed, please log in again", "email": user.PendingEmail})
+	}
+}
+
+// ChangePassword replaces the current user's password after checking the
+// old one. Every existing token is revoked and the caller gets a new pair,
+// so only this session stays signed in.
+func ChangePassword() gin.HandlerFunc {
+	return func(_context *gin.Context) {
+		var request models.PasswordChangeRequest
+		if err := _context.BindJSON(&request); err != nil {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+
+		var validate = validator.New()
+		if err := validate.Struct(request); err != nil {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "details": err.Error()})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		user, ok := getCurrentUser(ctx, _context)
+		if !ok {
+			return
+		}
+
+		if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(request.OldPassword)); err != nil {
+			_context.JSON(http.StatusUnauthorized, gin.H{"error": "Old password is incorrect"})
+			return
+		}
+
+		hashedPassword, err := HashPassword(request.NewPassword)
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error hashing password"})
+			return
+		}
+
+		// Matching the old hash stops two concurrent changes from both
+		// passing the check above.
+		result, err := userCollection.UpdateOne(
+			ctx,
+			bson.M{"user_id": user.UserID, "password": user.Password},
+			bson.M{"$set": bson.M{"password": hashedPassword, "updated_at": time.Now()}},
+		)
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating password"})
+			return
+		}
+		if result.MatchedCount == 0 {
+			_context.JSON(http.StatusConflict, gin.H{"error": "Password was changed concurrently, retry"})
+			return
+		}
+
+		if err := utils.RevokeAllUserTokens(user.UserID); err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error revoking tokens"})
+			return
+		}
+
+		token, refreshToken, err := utils.GenerateAllTokens(user.Email, user.FirstName, user.LastName, user.UserID, user.Role, utils.NewTokenFamily(), user.EmailVerified)
+		if err == nil {
+			err = utils.AllowTokenAfterRevocation(user.UserID, utils.TokenID(token))
+		}
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Password changed, but error generating tokens, please log in again"})
+			return
+		}
+
+		_context.JSON(http.StatusOK, gin.H{
+			"message":          "Password changed successfully",
+			"st-access-token":  token,
+			"st-refresh-token": refreshToken,
+		})
+	}
+}
@@ -0,0 +1,257 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Neph-dev/MovieStreamServer/llm"
+	"github.com/Neph-dev/MovieStreamServer/models"
+	"github.com/Neph-dev/MovieStreamServer/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// Shares the movies client so the active prompt can be swapped in a
+// transaction.
+var promptCollection *mongo.Collection = movieCollection.Database().Collection("prompt_templates")
+
+var promptIndexesOnce sync.Once
+
+func ensurePromptIndexes(ctx context.Context) {
+	promptIndexesOnce.Do(func() {
+		_, err := promptCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+			{Keys: bson.D{{Key: "version", Value: 1}}, Options: options.Index().SetUnique(true)},
+			{
+				Keys: bson.D{{Key: "is_active", Value: 1}},
+				Options: options.Index().
+					SetName("single_active_prompt").
+					SetUnique(true).
+					SetPartialFilterExpression(bson.M{"is_active": true}),
+			},
+		})
+		if err != nil {
+			log.Println("Warning: could not create prompt template indexes:", err)
+		}
+	})
+}
+
+// MigratePromptTemplates stores BASE_PROMPT_TEMPLATE as the first, active
+// prompt version when no versions exist yet.
+func MigratePromptTemplates() {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	ensurePromptIndexes(ctx)
+
+	source := os.Getenv("BASE_PROMPT_TEMPLATE")
+	if source == "" {
+		return
+	}
+
+	exists, err := utils.DocumentExists(ctx, promptCollection, bson.M{})
+	if err != nil || exists {
+		if err != nil {
+			log.Println("Error migrating prompt templates:", err)
+		}
+		return
+	}
+
+	now := time.Now()
+	prompt := models.PromptTemplate{
+		Version:     1,
+		Description: "Imported from BASE_PROMPT_TEMPLATE",
+		Template:    source,
+		IsActive:    true,
+		CreatedBy:   "system",
+		CreatedAt:   now,
+		ActivatedAt: &now,
+	}
+
+	if err := utils.InsertDocument(ctx, promptCollection, prompt); err != nil && !mongo.IsDuplicateKeyError(err) {
+		log.Println("Error migrating prompt templates:", err)
+	}
+}
+
+// GetActivePromptTemplate returns the active prompt version, or nil when
+// there is none and rankers fall back to BASE_PROMPT_TEMPLATE.
+func GetActivePromptTemplate(ctx context.Context) (*models.PromptTemplate, error) {
+	var prompt models.PromptTemplate
+
+	err := promptCollection.FindOne(ctx, bson.M{"is_active": true}).Decode(&prompt)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &prompt, nil
+}
+
+func ListPromptTemplates() gin.HandlerFunc {
+	return func(_context *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		cursor, err := promptCollection.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "version", Value: -1}}))
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching prompt templates"})
+			return
+		}
+		defer cursor.Close(ctx)
+
+		prompts := []models.PromptTemplate{}
+		if err = cursor.All(ctx, &prompts); err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error decoding prompt templates"})
+			return
+		}
+
+		_context.JSON(http.StatusOK, prompts)
+	}
+}
+
+// CreatePromptTemplate stores a new prompt version. Versions are never edited
+// so that every stored ranking can be traced back to the exact prompt; with
+// "activate": true the new version is used from now on.
+func CreatePromptTemplate() gin.HandlerFunc {
+	return func(_context *gin.Context) {
+		userId, err := utils.GetDataFromContext(_context, "userId")
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving user data from context"})
+			return
+		}
+
+		var request struct {
+			Template    string `json:"template"`
+			Description string `json:"description"`
+			Activate    bool   `json:"activate"`
+		}
+
+		if err := _context.BindJSON(&request); err != nil {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+
+		prompt := models.PromptTemplate{
+			Template:    request.Template,
+			Description: strings.TrimSpace(request.Description),
+			CreatedBy:   userId,
+			CreatedAt:   time.Now(),
+		}
+
+		var validate = validator.New()
+		if err := validate.Struct(prompt); err != nil {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := llm.ValidatePromptTemplate(prompt.Template); err != nil {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": "Invalid prompt template: " + err.Error()})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		ensurePromptIndexes(ctx)
+
+		// Two admins creating a version at once race for the same number;
+		// the unique index turns the loser into a retry.
+		for attempt := 0; ; attempt++ {
+			var latest models.PromptTemplate
+			err := promptCollection.FindOne(ctx, bson.M{}, options.FindOne().SetSort(bson.D{{Key: "version", Value: -1}})).Decode(&latest)
+			if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+				_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating prompt template"})
+				return
+			}
+
+			prompt.Version = latest.Version + 1
+
+			err = utils.InsertDocument(ctx, promptCollection, prompt)
+			if err == nil {
+				break
+			}
+			if !mongo.IsDuplicateKeyError(err) || attempt == 2 {
+				_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating prompt template"})
+				return
+			}
+		}
+
+		if request.Activate {
+			activated, err := activatePromptVersion(ctx, prompt.Version)
+			if err != nil {
+				_context.JSON(http.StatusInternalServerError, gin.H{"error": "Prompt template created but could not be activated"})
+				return
+			}
+			prompt = activated
+		}
+
+		_context.JSON(http.StatusCreated, prompt)
+	}
+}
+
+func ActivatePromptTemplate() gin.HandlerFunc {
+	return func(_context *gin.Context) {
+		version, err := strconv.Atoi(_context.Param("version"))
+		if err != nil {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": "version must be an integer"})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		prompt, err := activatePromptVersion(ctx, version)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			_context.JSON(http.StatusNotFound, gin.H{"error": "Prompt template not found"})
+			return
+		}
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error activating prompt template"})
+			return
+		}
+
+		_context.JSON(http.StatusOK, prompt)
+	}
+}
+
+// activatePromptVersion makes version the active prompt. The current one is
+// deactivated first so the single-active index is never violated, in the
+// same transaction so there is always an active prompt.
+func activatePromptVersion(ctx context.Context, version int) (models.PromptTemplate, error) {
+	var prompt models.PromptTemplate
+
+	if err := promptCollection.FindOne(ctx, bson.M{"version": version}).Decode(&prompt); err != nil {
+		return prompt, err
+	}
+
+	err := withTransaction(ctx, func(ctx context.Context) error {
+		_, err := promptCollection.UpdateMany(
+			ctx,
+			bson.M{"is_active": true, "version": bson.M{"$ne": version}},
+			bson.M{"$set": bson.M{"is_active": false}},
+		)
+		if err != nil {
+			return err
+		}
+
+		return promptCollection.FindOneAndUpdate(
+			ctx,
+			bson.M{"version": version},
+			bson.M{"$set": bson.M{"is_active": true, "activated_at": time.Now()}},
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&prompt)
+	})
+
+	return prompt, err
+}
@@ -0,0 +1,447 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Neph-dev/MovieStreamServer/models"
+	"github.com/Neph-dev/MovieStreamServer/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// legacyUnrankedValue is the ranking_value that marked the "unranked" ranking
+// before the is_default flag existed.
+const legacyUnrankedValue = 999
+
+var rankingIndexesOnce sync.Once
+
+var rankingNameCollation = &options.Collation{Locale: "en", Strength: 2}
+
+func ensureRankingIndexes(ctx context.Context) {
+	rankingIndexesOnce.Do(func() {
+		_, err := rankingCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+			{Keys: bson.D{{Key: "ranking_value", Value: 1}}, Options: options.Index().SetUnique(true)},
+			{Keys: bson.D{{Key: "ranking_name", Value: 1}}, Options: options.Index().SetUnique(true).SetCollation(rankingNameCollation)},
+			{
+				Keys: bson.D{{Key: "is_default", Value: 1}},
+				Options: options.Index().
+					SetName("single_default_ranking").
+					SetUnique(true).
+					SetPartialFilterExpression(bson.M{"is_default": true}),
+			},
+		})
+		if err != nil {
+			log.Println("Warning: could not create rankings indexes:", err)
+		}
+	})
+}
+
+// MigrateRankingDefaults gives rankings created before the is_default flag an
+// explicit value, turning the old 999 "unranked" convention into the flag.
+func MigrateRankingDefaults() {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	_, err := rankingCollection.UpdateMany(
+		ctx,
+		bson.M{"is_default": bson.M{"$exists": false}, "ranking_value": legacyUnrankedValue},
+		bson.M{"$set": bson.M{"is_default": true}},
+	)
+	if err == nil {
+		_, err = rankingCollection.UpdateMany(
+			ctx,
+			bson.M{"is_default": bson.M{"$exists": false}},
+			bson.M{"$set": bson.M{"is_default": false}},
+		)
+	}
+	if err != nil {
+		log.Println("Error migrating ranking defaults:", err)
+	}
+
+	ensureRankingIndexes(ctx)
+}
+
+func getRankingValueParam(_context *gin.Context) (int, bool) {
+	value, err := strconv.Atoi(_context.Param("ranking_value"))
+	if err != nil {
+		_context.JSON(http.StatusBadRequest, gin.H{"error": "ranking_value must be an integer"})
+		return 0, false
+	}
+
+	return value, true
+}
+
+func respondRankingWriteError(_context *gin.Context, err error, action string) {
+	if mongo.IsDuplicateKeyError(err) {
+		_context.JSON(http.StatusConflict, gin.H{"error": "A ranking with this value or name already exists, or another ranking is already the default"})
+		return
+	}
+
+	_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error " + action + " ranking"})
+}
+
+// errDefaultRankingRequired rejects a change that would leave no default
+// ranking, which every admin review falls back to.
+var errDefaultRankingRequired = errors.New("the default ranking can only be unset or deleted with default_to naming the new default")
+
+var errDefaultToNotFound = errors.New("default_to ranking not found")
+
+// clearDefaultRanking unsets is_default on every ranking except keepValue, so
+// a new default can be set without tripping the single-default index.
+func clearDefaultRanking(ctx context.Context, keepValue int) error {
+	_, err := rankingCollection.UpdateMany(
+		ctx,
+		bson.M{"is_default": true, "ranking_value": bson.M{"$ne": keepValue}},
+		bson.M{"$set": bson.M{"is_default": false, "updated_at": time.Now()}},
+	)
+	return err
+}
+
+// setDefaultRanking makes value the default ranking. Run it in a transaction,
+// so the old default is only cleared if the new one is set. It returns
+// mongo.ErrNoDocuments when no ranking has the value.
+func setDefaultRanking(ctx context.Context, value int) error {
+	if err := clearDefaultRanking(ctx, value); err != nil {
+		return err
+	}
+
+	result, err := rankingCollection.UpdateOne(
+		ctx,
+		bson.M{"ranking_value": value},
+		bson.M{"$set": bson.M{"is_default": true, "updated_at": time.Now()}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	return nil
+}
+
+// errUnknownRanking is returned by ResolveRanking for a ranking_value that
+// is not in the rankings collection.
+var errUnknownRanking = errors.New("unknown ranking_value")
+
+// ResolveRanking looks ranking up by ranking_value and returns it with its
+// canonical name.
+func ResolveRanking(ctx context.Context, ranking models.Ranking) (models.Ranking, error) {
+	var definition models.RankingDefinition
+	err := rankingCollection.FindOne(ctx, bson.M{"ranking_value": ranking.RankingValue}).Decode(&definition)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return ranking, fmt.Errorf("%w: %d", errUnknownRanking, ranking.RankingValue)
+	}
+	if err != nil {
+		return ranking, err
+	}
+
+	return definition.Ranking(), nil
+}
+
+func ListRankings() gin.HandlerFunc {
+	return func(_context *gin.Context) {
+		rankings, err := GetRankings()
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching rankings from database"})
+			return
+		}
+
+		if rankings == nil {
+			rankings = []models.RankingDefinition{}
+		}
+
+		_context.JSON(http.StatusOK, rankings)
+	}
+}
+
+func CreateRanking() gin.HandlerFunc {
+	return func(_context *gin.Context) {
+		var ranking models.RankingDefinition
+
+		if err := _context.BindJSON(&ranking); err != nil {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+
+		ranking.ID = bson.ObjectID{}
+		ranking.RankingName = strings.TrimSpace(ranking.RankingName)
+		ranking.CreatedAt = time.Now()
+		ranking.UpdatedAt = time.Now()
+
+		var validate = validator.New()
+		if err := validate.Struct(ranking); err != nil {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		ensureRankingIndexes(ctx)
+
+		// The old default is only cleared once the new ranking is in.
+		err := withTransaction(ctx, func(ctx context.Context) error {
+			if err := utils.InsertDocument(ctx, rankingCollection, ranking); err != nil {
+				return err
+			}
+			if ranking.IsDefault {
+				return clearDefaultRanking(ctx, ranking.RankingValue)
+			}
+			return nil
+		})
+		if err != nil {
+			respondRankingWriteError(_context, err, "creating")
+			return
+		}
+
+		_context.JSON(http.StatusCreated, ranking)
+	}
+}
+
+// UpdateRanking changes a ranking's name, order or default flag. A new name
+// is copied into every movie that carries the ranking. Unsetting is_default
+// on the default ranking needs default_to, the ranking_value of the ranking
+// that becomes the default instead.
+func UpdateRanking() gin.HandlerFunc {
+	return func(_context *gin.Context) {
+		value, ok := getRankingValueParam(_context)
+		if !ok {
+			return
+		}
+
+		var update struct {
+			RankingName *string `json:"ranking_name" validate:"omitempty,min=2,max=100"`
+			IsDefault   *bool   `json:"is_default"`
+			Order       *int    `json:"order"`
+			DefaultTo   *int    `json:"default_to"`
+		}
+
+		if err := _context.BindJSON(&update); err != nil {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+
+		if update.RankingName != nil {
+			trimmed := strings.TrimSpace(*update.RankingName)
+			update.RankingName = &trimmed
+		}
+
+		var validate = validator.New()
+		if err := validate.Struct(update); err != nil {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		fields := bson.M{"updated_at": time.Now()}
+		if update.RankingName != nil {
+			fields["ranking_name"] = *update.RankingName
+		}
+		if update.IsDefault != nil {
+			fields["is_default"] = *update.IsDefault
+		}
+		if update.Order != nil {
+			fields["order"] = *update.Order
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		ensureRankingIndexes(ctx)
+
+		var current models.RankingDefinition
+		if err := rankingCollection.FindOne(ctx, bson.M{"ranking_value": value}).Decode(&current); err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				_context.JSON(http.StatusNotFound, gin.H{"error": "Ranking not found"})
+				return
+			}
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching ranking"})
+			return
+		}
+
+		unsetsDefault := current.IsDefault && update.IsDefault != nil && !*update.IsDefault
+		if unsetsDefault && (update.DefaultTo == nil || *update.DefaultTo == value) {
+			_context.JSON(http.StatusConflict, gin.H{"error": errDefaultRankingRequired.Error()})
+			return
+		}
+
+		var ranking models.RankingDefinition
+		err := withTransaction(ctx, func(ctx context.Context) error {
+			if update.IsDefault != nil && *update.IsDefault {
+				if err := clearDefaultRanking(ctx, value); err != nil {
+					return err
+				}
+			}
+
+			err := rankingCollection.FindOneAndUpdate(
+				ctx,
+				bson.M{"ranking_value": value},
+				bson.M{"$set": fields},
+				options.FindOneAndUpdate().SetReturnDocument(options.After),
+			).Decode(&ranking)
+			if err != nil {
+				return err
+			}
+
+			if unsetsDefault {
+				if err := setDefaultRanking(ctx, *update.DefaultTo); err != nil {
+					if errors.Is(err, mongo.ErrNoDocuments) {
+						return errDefaultToNotFound
+					}
+					return err
+				}
+			}
+			return nil
+		})
+		if errors.Is(err, errDefaultToNotFound) {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			respondRankingWriteError(_context, err, "updating")
+			return
+		}
+
+		var moviesUpdated int64
+		if update.RankingName != nil {
+			result, err := movieCollection.UpdateMany(
+				ctx,
+				bson.M{"ranking.ranking_value": value},
+				bson.M{"$set": bson.M{"ranking.ranking_name": ranking.RankingName}, "$inc": bson.M{"version": 1}},
+			)
+			if err != nil {
+				_context.JSON(http.StatusInternalServerError, gin.H{"error": "Ranking renamed but updating movies failed, retry the update"})
+				return
+			}
+			moviesUpdated = result.ModifiedCount
+		}
+
+		_context.JSON(http.StatusOK, gin.H{
+			"ranking":        ranking,
+			"movies_updated": moviesUpdated,
+		})
+	}
+}
+
+// DeleteRanking refuses to delete a ranking that movies still use unless
+// remap_to names another ranking_value to move those movies to first. The
+// default ranking also needs default_to, the ranking that becomes the
+// default in its place.
+func DeleteRanking() gin.HandlerFunc {
+	return func(_context *gin.Context) {
+		value, ok := getRankingValueParam(_context)
+		if !ok {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var ranking models.RankingDefinition
+		if err := rankingCollection.FindOne(ctx, bson.M{"ranking_value": value}).Decode(&ranking); err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				_context.JSON(http.StatusNotFound, gin.H{"error": "Ranking not found"})
+				return
+			}
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching ranking"})
+			return
+		}
+
+		newDefault := 0
+		if ranking.IsDefault {
+			parsed, err := strconv.Atoi(_context.Query("default_to"))
+			if err != nil || parsed == value {
+				_context.JSON(http.StatusConflict, gin.H{"error": errDefaultRankingRequired.Error()})
+				return
+			}
+			newDefault = parsed
+
+			exists, err := utils.DocumentExists(ctx, rankingCollection, bson.M{"ranking_value": newDefault})
+			if err != nil {
+				_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching ranking"})
+				return
+			}
+			if !exists {
+				_context.JSON(http.StatusBadRequest, gin.H{"error": errDefaultToNotFound.Error()})
+				return
+			}
+		}
+
+		inUse, err := movieCollection.CountDocuments(ctx, bson.M{"ranking.ranking_value": value})
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking ranking usage"})
+			return
+		}
+
+		var moviesRemapped int64
+
+		if inUse > 0 {
+			remapParam := _context.Query("remap_to")
+			if remapParam == "" {
+				_context.JSON(http.StatusConflict, gin.H{
+					"error":        "Ranking is still used by movies, pass remap_to to move them to another ranking",
+					"movies_count": inUse,
+				})
+				return
+			}
+
+			remapValue, err := strconv.Atoi(remapParam)
+			if err != nil || remapValue == value {
+				_context.JSON(http.StatusBadRequest, gin.H{"error": "remap_to must be the ranking_value of another ranking"})
+				return
+			}
+
+			var target models.RankingDefinition
+			if err := rankingCollection.FindOne(ctx, bson.M{"ranking_value": remapValue}).Decode(&target); err != nil {
+				_context.JSON(http.StatusBadRequest, gin.H{"error": "remap_to ranking not found"})
+				return
+			}
+
+			result, err := movieCollection.UpdateMany(
+				ctx,
+				bson.M{"ranking.ranking_value": value},
+				bson.M{"$set": bson.M{"ranking": target.Ranking()}, "$inc": bson.M{"version": 1}},
+			)
+			if err != nil {
+				_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error remapping movies"})
+				return
+			}
+			moviesRemapped = result.ModifiedCount
+		}
+
+		err = withTransaction(ctx, func(ctx context.Context) error {
+			if ranking.IsDefault {
+				if err := setDefaultRanking(ctx, newDefault); err != nil {
+					return err
+				}
+			}
+
+			_, err := rankingCollection.DeleteOne(ctx, bson.M{"ranking_value": value})
+			return err
+		})
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": errDefaultToNotFound.Error()})
+			return
+		}
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error deleting ranking"})
+			return
+		}
+
+		_context.JSON(http.StatusOK, gin.H{
+			"message":         "Ranking deleted successfully",
+			"movies_remapped": moviesRemapped,
+		})
+	}
+}
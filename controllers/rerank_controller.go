@@ -0,0 +1,583 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	db "github.com/Neph-dev/MovieStreamServer/database"
+	"github.com/Neph-dev/MovieStreamServer/models"
+	"github.com/Neph-dev/MovieStreamServer/utils"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+var rerankJobCollection *mongo.Collection = db.OpenCollection("rerank_jobs")
+var rerankChangeCollection *mongo.Collection = db.OpenCollection("rerank_job_changes")
+
+const maxRerankConcurrency = 16
+
+// errRerankJobLost stops a re-rank job whose lease was taken over by another
+// server.
+var errRerankJobLost = errors.New("re-rank job was claimed by another worker")
+
+var rerankJobIndexesOnce sync.Once
+
+// ensureRerankJobIndexes creates the unique index that lets a single re-rank
+// job be active at a time.
+func ensureRerankJobIndexes(ctx context.Context) {
+	rerankJobIndexesOnce.Do(func() {
+		_, err := rerankJobCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+			{Keys: bson.D{{Key: "job_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+			{
+				Keys: bson.D{{Key: "active", Value: 1}},
+				Options: options.Index().
+					SetUnique(true).
+					SetPartialFilterExpression(bson.M{"active": true}),
+			},
+		})
+		if err != nil {
+			log.Println("Warning: could not create re-rank job indexes:", err)
+		}
+	})
+}
+
+// rerankJobLease is how long a claimed job stays locked without progress.
+// It is renewed before every batch, for as long as the batch can take at the
+// job's rate plus this margin.
+func rerankJobLease() time.Duration {
+	return time.Duration(utils.GetEnvInt("RERANK_JOB_LEASE_MINUTES", 10)) * time.Minute
+}
+
+// claimRerankJob locks the queued job matching filter, or a running one whose
+// lease has expired, for this server.
+func claimRerankJob(ctx context.Context, filter bson.M) (models.RerankJob, error) {
+	now := time.Now()
+
+	filter["$or"] = bson.A{
+		bson.M{"status": models.JobStatusQueued},
+		bson.M{"status": models.JobStatusRunning, "locked_until": bson.M{"$lt": now}},
+		bson.M{"status": models.JobStatusRunning, "locked_until": nil},
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"status":       models.JobStatusRunning,
+			"active":       true,
+			"locked_by":    reviewWorkerID(),
+			"locked_until": now.Add(rerankJobLease()),
+		},
+		"$min": bson.M{"started_at": now},
+	}
+	findOptions := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "created_at", Value: 1}}).
+		SetReturnDocument(options.After)
+
+	var job models.RerankJob
+	err := rerankJobCollection.FindOneAndUpdate(ctx, filter, update, findOptions).Decode(&job)
+
+	return job, err
+}
+
+// startRerankJob claims a job that was just queued and runs it in the
+// background. A job another server got to first is left to that server.
+func startRerankJob(ctx context.Context, jobID string) {
+	job, err := claimRerankJob(ctx, bson.M{"job_id": jobID})
+	if err != nil {
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			log.Printf("Error claiming re-rank job %s: %v", jobID, err)
+		}
+		return
+	}
+
+	go runRerankJob(job)
+}
+
+// rerankMovieFilter matches the movies a re-rank job goes through, the ones
+// with an admin review to rank.
+func rerankMovieFilter() bson.M {
+	return utils.ActiveFilter(bson.M{"admin_review": bson.M{"$nin": bson.A{"", nil}}})
+}
+
+func parseRerankInt(_context *gin.Context, key string, fallback int, max int) (int, bool) {
+	value := _context.Query(key)
+	if value == "" {
+		return fallback, true
+	}
+
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed < 1 || parsed > max {
+		return 0, false
+	}
+
+	return parsed, true
+}
+
+// StartRerank queues a job that recomputes the ranking of every reviewed
+// movie. With dry_run=true it only reports what would change.
+func StartRerank() gin.HandlerFunc {
+	return func(_context *gin.Context) {
+		userId, err := utils.GetDataFromContext(_context, "userId")
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving user data from context"})
+			return
+		}
+
+		dryRun, err := strconv.ParseBool(_context.DefaultQuery("dry_run", "false"))
+		if err != nil {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": "dry_run must be true or false"})
+			return
+		}
+
+		concurrency, ok := parseRerankInt(_context, "concurrency", utils.GetEnvInt("RERANK_CONCURRENCY", 4), maxRerankConcurrency)
+		if !ok {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": "concurrency must be between 1 and " + strconv.Itoa(maxRerankConcurrency)})
+			return
+		}
+
+		ratePerMinute, ok := parseRerankInt(_context, "rate_per_minute", utils.GetEnvInt("RERANK_RATE_PER_MINUTE", 60), 6000)
+		if !ok {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": "rate_per_minute must be between 1 and 6000"})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		ensureRerankJobIndexes(ctx)
+
+		total, err := movieCollection.CountDocuments(ctx, rerankMovieFilter())
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error counting movies"})
+			return
+		}
+
+		job := models.RerankJob{
+			JobID:         bson.NewObjectID().Hex(),
+			Status:        models.JobStatusQueued,
+			DryRun:        dryRun,
+			Concurrency:   concurrency,
+			RatePerMinute: ratePerMinute,
+			Counts:        models.RerankCounts{Total: total},
+			CreatedBy:     userId,
+			Active:        true,
+			CreatedAt:     time.Now(),
+		}
+
+		if err := utils.InsertDocument(ctx, rerankJobCollection, job); err != nil {
+			if mongo.IsDuplicateKeyError(err) {
+				_context.JSON(http.StatusConflict, gin.H{"error": "A re-rank job is already running"})
+				return
+			}
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating re-rank job"})
+			return
+		}
+
+		startRerankJob(ctx, job.JobID)
+
+		_context.JSON(http.StatusAccepted, gin.H{
+			"message":    "Re-rank job queued",
+			"job_id":     job.JobID,
+			"status":     job.Status,
+			"status_url": "/admin/rerank/" + job.JobID,
+		})
+	}
+}
+
+func GetRerankJob() gin.HandlerFunc {
+	return func(_context *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var job models.RerankJob
+		err := rerankJobCollection.FindOne(ctx, bson.M{"job_id": _context.Param("job_id")}).Decode(&job)
+		if err != nil {
+			_context.JSON(http.StatusNotFound, gin.H{"error": "Re-rank job not found"})
+			return
+		}
+
+		_context.JSON(http.StatusOK, job)
+	}
+}
+
+// GetRerankChanges pages through the diff report of a re-rank job;
+// changed=true leaves out the movies whose ranking stayed the same.
+func GetRerankChanges() gin.HandlerFunc {
+	return func(_context *gin.Context) {
+		limit, err := utils.GetLimitParam(_context, 100, 1000)
+		if err != nil {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		page, err := strconv.ParseInt(_context.DefaultQuery("page", "1"), 10, 64)
+		if err != nil || page < 1 {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": "page must be a positive integer"})
+			return
+		}
+
+		filter := bson.M{"job_id": _context.Param("job_id")}
+		if value := _context.Query("changed"); value != "" {
+			changed, err := strconv.ParseBool(value)
+			if err != nil {
+				_context.JSON(http.StatusBadRequest, gin.H{"error": "changed must be true or false"})
+				return
+			}
+			filter["changed"] = changed
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		total, err := rerankChangeCollection.CountDocuments(ctx, filter)
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error counting re-rank changes"})
+			return
+		}
+
+		findOptions := options.Find().
+			SetSort(bson.D{{Key: "_id", Value: 1}}).
+			SetSkip((page - 1) * limit).
+			SetLimit(limit)
+
+		cursor, err := rerankChangeCollection.Find(ctx, filter, findOptions)
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching re-rank changes"})
+			return
+		}
+		defer cursor.Close(ctx)
+
+		changes := []models.RerankChange{}
+		if err = cursor.All(ctx, &changes); err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error decoding re-rank changes"})
+			return
+		}
+
+		_context.JSON(http.StatusOK, gin.H{
+			"data": changes,
+			"pagination": utils.Pagination{
+				Limit:      limit,
+				Page:       page,
+				Total:      &total,
+				TotalPages: (total + limit - 1) / limit,
+				HasMore:    page*limit < total,
+			},
+		})
+	}
+}
+
+// ResumeRerankJob restarts a failed re-rank job from its last checkpoint.
+func ResumeRerankJob() gin.HandlerFunc {
+	return func(_context *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		ensureRerankJobIndexes(ctx)
+
+		var job models.RerankJob
+		err := rerankJobCollection.FindOneAndUpdate(
+			ctx,
+			bson.M{"job_id": _context.Param("job_id"), "status": models.JobStatusFailed},
+			bson.M{"$set": bson.M{"status": models.JobStatusQueued, "active": true}, "$unset": bson.M{"error": "", "finished_at": ""}},
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&job)
+		if mongo.IsDuplicateKeyError(err) {
+			_context.JSON(http.StatusConflict, gin.H{"error": "A re-rank job is already running"})
+			return
+		}
+		if err != nil {
+			_context.JSON(http.StatusNotFound, gin.H{"error": "No failed re-rank job with this id"})
+			return
+		}
+
+		startRerankJob(ctx, job.JobID)
+
+		_context.JSON(http.StatusAccepted, gin.H{
+			"message":    "Re-rank job resumed",
+			"job_id":     job.JobID,
+			"status":     job.Status,
+			"status_url": "/admin/rerank/" + job.JobID,
+		})
+	}
+}
+
+// RecoverRerankJobs starts a background loop that claims queued re-rank jobs
+// and those whose server stopped, and resumes each from its last checkpoint.
+// Jobs this server held before a restart are released first, so they do not
+// wait for their lease to run out.
+func RecoverRerankJobs() {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	ensureRerankJobIndexes(ctx)
+
+	_, err := rerankJobCollection.UpdateMany(
+		ctx,
+		bson.M{"status": models.JobStatusRunning, "locked_by": reviewWorkerID()},
+		bson.M{"$unset": bson.M{"locked_by": "", "locked_until": ""}},
+	)
+	if err != nil {
+		log.Println("Error releasing re-rank jobs:", err)
+	}
+
+	go func() {
+		pollInterval := time.Duration(utils.GetEnvInt("RERANK_POLL_SECONDS", 60)) * time.Second
+
+		for {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			job, err := claimRerankJob(ctx, bson.M{})
+			cancel()
+
+			if err != nil {
+				if !errors.Is(err, mongo.ErrNoDocuments) {
+					log.Println("Error claiming re-rank job:", err)
+				}
+				time.Sleep(pollInterval)
+				continue
+			}
+
+			log.Printf("Resuming re-rank job %s", job.JobID)
+			runRerankJob(job)
+		}
+	}()
+}
+
+type movieReranker struct {
+	job     models.RerankJob
+	limiter *time.Ticker
+}
+
+func runRerankJob(job models.RerankJob) {
+	reranker := &movieReranker{
+		job:     job,
+		limiter: time.NewTicker(time.Minute / time.Duration(job.RatePerMinute)),
+	}
+	defer reranker.limiter.Stop()
+
+	err := reranker.run()
+	if errors.Is(err, errRerankJobLost) {
+		log.Printf("Re-rank job %s: %v", job.JobID, err)
+		return
+	}
+	if err != nil {
+		log.Printf("Re-rank job %s failed: %v", job.JobID, err)
+		reranker.finish(bson.M{"status": models.JobStatusFailed, "error": err.Error(), "finished_at": time.Now()})
+		return
+	}
+
+	reranker.finish(bson.M{"status": models.JobStatusCompleted, "finished_at": time.Now()})
+}
+
+// run works through the movies in _id order, one batch at a time, and saves a
+// checkpoint after each batch. A batch interrupted halfway is redone on
+// resume; its report lines are upserted so they are not duplicated.
+func (reranker *movieReranker) run() error {
+	batchSize := int64(reranker.job.Concurrency * 10)
+
+	for {
+		if err := reranker.renewLease(); err != nil {
+			return err
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+
+		filter := rerankMovieFilter()
+		if reranker.job.Checkpoint != nil {
+			filter["_id"] = bson.M{"$gt": *reranker.job.Checkpoint}
+		}
+
+		findOptions := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetLimit(batchSize)
+
+		var movies []models.Movie
+		cursor, err := movieCollection.Find(ctx, filter, findOptions)
+		if err == nil {
+			err = cursor.All(ctx, &movies)
+		}
+		cancel()
+
+		if err != nil {
+			return err
+		}
+		if len(movies) == 0 {
+			return nil
+		}
+
+		changes := reranker.rerankBatch(movies)
+
+		// Once the budget runs out the rest of the catalog would only fail,
+		// so the job stops here and can be resumed from this batch later.
+		for _, change := range changes {
+			if change.Error == ErrLLMBudgetExceeded.Error() {
+				return ErrLLMBudgetExceeded
+			}
+		}
+
+		if err := reranker.saveBatch(changes, movies[len(movies)-1].ID); err != nil {
+			return err
+		}
+	}
+}
+
+func (reranker *movieReranker) rerankBatch(movies []models.Movie) []models.RerankChange {
+	changes := make([]models.RerankChange, len(movies))
+	slots := make(chan struct{}, reranker.job.Concurrency)
+
+	var wg sync.WaitGroup
+	for i, movie := range movies {
+		wg.Add(1)
+		slots <- struct{}{}
+
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			changes[i] = reranker.rerankMovie(movie)
+		}()
+	}
+	wg.Wait()
+
+	return changes
+}
+
+func (reranker *movieReranker) rerankMovie(movie models.Movie) models.RerankChange {
+	change := models.RerankChange{
+		JobID:      reranker.job.JobID,
+		ImdbID:     movie.ImdbID,
+		Title:      movie.Title,
+		OldRanking: movie.Ranking,
+	}
+
+	<-reranker.limiter.C
+
+	rankingResult, err := GetReviewRanking(movie, reranker.job.CreatedBy)
+	if err != nil {
+		change.Error = err.Error()
+		return change
+	}
+
+	change.NewRanking = &rankingResult.Ranking
+	change.Changed = rankingResult.Ranking != movie.Ranking || movie.RankingStatus != models.RankingStatusRanked
+
+	if reranker.job.DryRun {
+		return change
+	}
+
+	// Unchanged rankings are written too, so that they record the prompt
+	// version and model that confirmed them. Only a changed ranking bumps the
+	// version, so a re-rank does not break the If-Match of every edit in
+	// flight.
+	fields := bson.M{"ranking_source": rankingSource(rankingResult)}
+	update := bson.M{"$set": fields}
+	if change.Changed {
+		fields["ranking"] = rankingResult.Ranking
+		fields["ranking_status"] = models.RankingStatusRanked
+		update["$inc"] = bson.M{"version": 1}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	// The review may have been replaced while it was being ranked, in which
+	// case the review job of the new review owns the ranking.
+	result, err := movieCollection.UpdateOne(
+		ctx,
+		utils.ActiveFilter(bson.M{"_id": movie.ID, "admin_review": movie.AdminReview}),
+		update,
+	)
+	if err != nil {
+		change.Error = err.Error()
+		return change
+	}
+
+	change.Applied = change.Changed && result.MatchedCount > 0
+
+	return change
+}
+
+func (reranker *movieReranker) saveBatch(changes []models.RerankChange, checkpoint bson.ObjectID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	counts := reranker.job.Counts
+	for _, change := range changes {
+		_, err := rerankChangeCollection.UpdateOne(
+			ctx,
+			bson.M{"job_id": change.JobID, "imdb_id": change.ImdbID},
+			bson.M{"$set": change},
+			options.UpdateOne().SetUpsert(true),
+		)
+		if err != nil {
+			return err
+		}
+
+		counts.Processed++
+		switch {
+		case change.Error != "":
+			counts.Failed++
+		case change.Changed:
+			counts.Changed++
+		default:
+			counts.Unchanged++
+		}
+	}
+
+	result, err := rerankJobCollection.UpdateOne(
+		ctx,
+		reranker.ownedFilter(),
+		bson.M{"$set": bson.M{"checkpoint": checkpoint, "counts": counts}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errRerankJobLost
+	}
+
+	reranker.job.Checkpoint = &checkpoint
+	reranker.job.Counts = counts
+
+	return nil
+}
+
+// ownedFilter matches the job only while this server still holds its lease,
+// so a server whose lease expired cannot overwrite the job's new owner.
+func (reranker *movieReranker) ownedFilter() bson.M {
+	return bson.M{"job_id": reranker.job.JobID, "locked_by": reranker.job.LockedBy}
+}
+
+// renewLease extends the lease to cover the next batch at the job's rate.
+func (reranker *movieReranker) renewLease() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	batch := time.Duration(reranker.job.Concurrency*10) * time.Minute / time.Duration(reranker.job.RatePerMinute)
+
+	result, err := rerankJobCollection.UpdateOne(
+		ctx,
+		reranker.ownedFilter(),
+		bson.M{"$set": bson.M{"locked_until": time.Now().Add(batch + rerankJobLease())}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errRerankJobLost
+	}
+
+	return nil
+}
+
+func (reranker *movieReranker) finish(fields bson.M) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	update := bson.M{"$set": fields, "$unset": bson.M{"active": "", "locked_by": "", "locked_until": ""}}
+	if _, err := rerankJobCollection.UpdateOne(ctx, reranker.ownedFilter(), update); err != nil {
+		log.Printf("Error updating re-rank job %s: %v", reranker.job.JobID, err)
+	}
+}
@@ -0,0 +1,243 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Neph-dev/MovieStreamServer/models"
+	"github.com/Neph-dev/MovieStreamServer/utils"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// Shares the movies client so history entries can be written in the same
+// transaction as the review they record.
+var reviewHistoryCollection *mongo.Collection = movieCollection.Database().Collection("review_history")
+
+// saveAdminReview writes entry.NewReview to the movie and appends entry to
+// its review history in one transaction. An entry that already carries a
+// ranked result, as a revert does, is saved as ranked; otherwise the movie
+// gets the default ranking and a review job to rank it is queued in the same
+// transaction, so a pending movie always has a job.
+func saveAdminReview(ctx context.Context, entry models.ReviewHistoryEntry) (models.ReviewHistoryEntry, error) {
+	update := bson.M{"$inc": bson.M{"version": 1}}
+
+	if entry.RankingStatus == models.RankingStatusRanked {
+		fields := bson.M{
+			"admin_review":   entry.NewReview,
+			"ranking":        entry.Ranking,
+			"ranking_status": models.RankingStatusRanked,
+		}
+		if entry.RankingSource != nil {
+			fields["ranking_source"] = entry.RankingSource
+		} else {
+			update["$unset"] = bson.M{"ranking_source": ""}
+		}
+		update["$set"] = fields
+	} else {
+		defaultRanking, err := GetDefaultRanking()
+		if err != nil {
+			return entry, err
+		}
+
+		entry.Ranking = defaultRanking
+		entry.RankingStatus = models.RankingStatusPending
+		entry.RankingSource = nil
+		entry.JobID = bson.NewObjectID().Hex()
+
+		update["$set"] = bson.M{
+			"admin_review":   entry.NewReview,
+			"ranking":        defaultRanking,
+			"ranking_status": models.RankingStatusPending,
+		}
+		update["$unset"] = bson.M{"ranking_source": ""}
+	}
+
+	err := withTransaction(ctx, func(ctx context.Context) error {
+		var previous models.Movie
+		err := movieCollection.FindOneAndUpdate(
+			ctx,
+			utils.ActiveFilter(bson.M{"imdb_id": entry.ImdbID}),
+			update,
+			options.FindOneAndUpdate().SetReturnDocument(options.Before),
+		).Decode(&previous)
+		if err != nil {
+			return err
+		}
+
+		entry.PreviousReview = previous.AdminReview
+		entry.PreviousRanking = previous.Ranking
+		entry.CreatedAt = time.Now()
+		entry.ID = bson.NewObjectID()
+
+		if _, err = reviewHistoryCollection.InsertOne(ctx, entry); err != nil {
+			return err
+		}
+
+		if entry.RankingStatus == models.RankingStatusPending {
+			_, err = EnqueueReviewJob(ctx, entry.JobID, entry.ImdbID, entry.NewReview, entry.AuthorID)
+		}
+		return err
+	})
+
+	return entry, err
+}
+
+// updateReviewHistoryRanking copies the outcome of a review job into the
+// history entry that queued it.
+func updateReviewHistoryRanking(jobID string, fields bson.M) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	if _, err := reviewHistoryCollection.UpdateOne(ctx, bson.M{"job_id": jobID}, bson.M{"$set": fields}); err != nil {
+		log.Printf("Error updating review history of job %s: %v", jobID, err)
+	}
+}
+
+func respondReviewSaveError(_context *gin.Context, err error) {
+	switch {
+	case errors.Is(err, mongo.ErrNoDocuments):
+		_context.JSON(http.StatusNotFound, gin.H{"error": "Movie not found"})
+	default:
+		_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating admin review"})
+	}
+}
+
+func respondReviewSaved(_context *gin.Context, entry models.ReviewHistoryEntry) {
+	if entry.RankingStatus == models.RankingStatusRanked {
+		_context.JSON(http.StatusOK, gin.H{
+			"message":        "Admin review reverted",
+			"admin_review":   entry.NewReview,
+			"ranking_name":   entry.Ranking.RankingName,
+			"ranking_status": entry.RankingStatus,
+			"history_id":     entry.ID.Hex(),
+		})
+		return
+	}
+
+	_context.JSON(http.StatusAccepted, gin.H{
+		"message":        "Admin review saved, ranking is pending",
+		"admin_review":   entry.NewReview,
+		"ranking_status": entry.RankingStatus,
+		"history_id":     entry.ID.Hex(),
+		"job_id":         entry.JobID,
+		"status_url":     "/review-jobs/" + entry.JobID,
+	})
+}
+
+// GetReviewHistory pages through a movie's review changes, newest first.
+func GetReviewHistory() gin.HandlerFunc {
+	return func(_context *gin.Context) {
+		limit, err := utils.GetLimitParam(_context, 20, 100)
+		if err != nil {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		page, err := strconv.ParseInt(_context.DefaultQuery("page", "1"), 10, 64)
+		if err != nil || page < 1 {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": "page must be a positive integer"})
+			return
+		}
+
+		filter := bson.M{"imdb_id": _context.Param("imdb_id")}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		total, err := reviewHistoryCollection.CountDocuments(ctx, filter)
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error counting review history"})
+			return
+		}
+
+		findOptions := options.Find().
+			SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}).
+			SetSkip((page - 1) * limit).
+			SetLimit(limit)
+
+		cursor, err := reviewHistoryCollection.Find(ctx, filter, findOptions)
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching review history"})
+			return
+		}
+		defer cursor.Close(ctx)
+
+		entries := []models.ReviewHistoryEntry{}
+		if err = cursor.All(ctx, &entries); err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error decoding review history"})
+			return
+		}
+
+		_context.JSON(http.StatusOK, gin.H{
+			"data": entries,
+			"pagination": utils.Pagination{
+				Limit:      limit,
+				Page:       page,
+				Total:      &total,
+				TotalPages: (total + limit - 1) / limit,
+				HasMore:    page*limit < total,
+			},
+		})
+	}
+}
+
+// RevertReview restores the review saved by a history entry. The entry's
+// ranking is restored with it when it had been ranked, otherwise the review
+// is ranked again. The revert is itself recorded in the history.
+func RevertReview() gin.HandlerFunc {
+	return func(_context *gin.Context) {
+		imdbID := _context.Param("imdb_id")
+
+		entryID, err := bson.ObjectIDFromHex(_context.Param("history_id"))
+		if err != nil {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": "Invalid history id"})
+			return
+		}
+
+		userId, err := utils.GetDataFromContext(_context, "userId")
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving user data from context"})
+			return
+		}
+		email, _ := utils.GetDataFromContext(_context, "email")
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var target models.ReviewHistoryEntry
+		err = reviewHistoryCollection.FindOne(ctx, bson.M{"_id": entryID, "imdb_id": imdbID}).Decode(&target)
+		if err != nil {
+			_context.JSON(http.StatusNotFound, gin.H{"error": "Review history entry not found"})
+			return
+		}
+
+		entry := models.ReviewHistoryEntry{
+			ImdbID:       imdbID,
+			Action:       models.ReviewActionRevert,
+			AuthorID:     userId,
+			AuthorEmail:  email,
+			NewReview:    target.NewReview,
+			RevertedFrom: &target.ID,
+		}
+		if target.RankingStatus == models.RankingStatusRanked {
+			entry.Ranking = target.Ranking
+			entry.RankingStatus = models.RankingStatusRanked
+			entry.RankingSource = target.RankingSource
+		}
+
+		entry, err = saveAdminReview(ctx, entry)
+		if err != nil {
+			respondReviewSaveError(_context, err)
+			return
+		}
+
+		respondReviewSaved(_context, entry)
+	}
+}
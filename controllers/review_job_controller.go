@@ -0,0 +1,358 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/Neph-dev/MovieStreamServer/models"
+	"github.com/Neph-dev/MovieStreamServer/utils"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// Shares the movies client so jobs can be queued in the same transaction as
+// the review they rank.
+var reviewJobCollection *mongo.Collection = movieCollection.Database().Collection("review_jobs")
+
+// ReviewJobSuperseded is the status of a job whose review was replaced by a
+// newer one before it could be ranked.
+const ReviewJobSuperseded = "superseded"
+
+const maxReviewJobBackoff = 30 * time.Minute
+
+// reviewWorkerID identifies this server process in the locked_by field of the
+// jobs it claims, so that it can take back its own jobs after a restart.
+func reviewWorkerID() string {
+	if id := os.Getenv("REVIEW_WORKER_ID"); id != "" {
+		return id
+	}
+
+	if hostname, err := os.Hostname(); err == nil {
+		return hostname
+	}
+
+	return "review-worker"
+}
+
+// reviewJobLease is how long a claimed job stays locked. It outlasts a whole
+// ranking so that only jobs of crashed workers are ever reclaimed.
+func reviewJobLease() time.Duration {
+	return llmTotalTimeout() + 30*time.Second
+}
+
+func reviewJobBackoff(attempts int) time.Duration {
+	delay := time.Duration(utils.GetEnvInt("REVIEW_JOB_RETRY_BASE_SECONDS", 30)) * time.Second
+	for i := 1; i < attempts && delay < maxReviewJobBackoff; i++ {
+		delay *= 2
+	}
+
+	return min(delay, maxReviewJobBackoff)
+}
+
+// EnqueueReviewJob stores a ranking job for the review, to be picked up by
+// the review workers.
+func EnqueueReviewJob(ctx context.Context, jobID string, imdbID string, review string, createdBy string) (models.ReviewJob, error) {
+	now := time.Now()
+
+	job := models.ReviewJob{
+		JobID:       jobID,
+		ImdbID:      imdbID,
+		Review:      review,
+		Status:      models.JobStatusQueued,
+		MaxAttempts: utils.GetEnvInt("REVIEW_JOB_MAX_ATTEMPTS", 5),
+		NextRunAt:   now,
+		CreatedBy:   createdBy,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	_, err := reviewJobCollection.InsertOne(ctx, job)
+
+	return job, err
+}
+
+func GetReviewJob() gin.HandlerFunc {
+	return func(_context *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var job models.ReviewJob
+		err := reviewJobCollection.FindOne(ctx, bson.M{"job_id": _context.Param("id")}).Decode(&job)
+		if err != nil {
+			_context.JSON(http.StatusNotFound, gin.H{"error": "Review job not found"})
+			return
+		}
+
+		_context.JSON(http.StatusOK, job)
+	}
+}
+
+// StartReviewWorkers requeues the jobs this process was running when it last
+// stopped and starts REVIEW_WORKERS workers that rank queued reviews.
+func StartReviewWorkers() {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	_, err := reviewJobCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "job_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "next_run_at", Value: 1}}},
+	})
+	if err != nil {
+		log.Println("Error creating review job indexes:", err)
+	}
+
+	workerID := reviewWorkerID()
+
+	// The interrupted attempt was not the job's fault, so it is given back.
+	result, err := reviewJobCollection.UpdateMany(
+		ctx,
+		bson.M{"status": models.JobStatusRunning, "locked_by": workerID},
+		bson.M{
+			"$set":   bson.M{"status": models.JobStatusQueued, "next_run_at": time.Now(), "updated_at": time.Now()},
+			"$unset": bson.M{"locked_by": "", "locked_until": ""},
+			"$inc":   bson.M{"attempts": -1},
+		},
+	)
+	if err != nil {
+		log.Println("Error recovering review jobs:", err)
+	} else if result.ModifiedCount > 0 {
+		log.Printf("Requeued %d interrupted review jobs", result.ModifiedCount)
+	}
+
+	requeueOrphanedReviews(ctx)
+
+	for i := 0; i < utils.GetEnvInt("REVIEW_WORKERS", 2); i++ {
+		go runReviewWorker(workerID)
+	}
+}
+
+// requeueOrphanedReviews queues a job for every pending movie that has none,
+// which reviews saved before jobs were queued transactionally can be left
+// with. The job reuses the id in the review's history entry when there is one.
+func requeueOrphanedReviews(ctx context.Context) {
+	cursor, err := movieCollection.Find(ctx, utils.ActiveFilter(bson.M{"ranking_status": models.RankingStatusPending}))
+	if err != nil {
+		log.Println("Error finding pending reviews:", err)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var movie models.Movie
+		if err := cursor.Decode(&movie); err != nil {
+			log.Println("Error decoding pending review:", err)
+			continue
+		}
+
+		active, err := utils.DocumentExists(ctx, reviewJobCollection, bson.M{
+			"imdb_id": movie.ImdbID,
+			"review":  movie.AdminReview,
+			"status":  bson.M{"$in": bson.A{models.JobStatusQueued, models.JobStatusRunning}},
+		})
+		if err != nil || active {
+			continue
+		}
+
+		var entry models.ReviewHistoryEntry
+		historyFilter := bson.M{"imdb_id": movie.ImdbID, "new_review": movie.AdminReview, "job_id": bson.M{"$nin": bson.A{"", nil}}}
+		historyOptions := options.FindOne().SetSort(bson.D{{Key: "created_at", Value: -1}})
+
+		jobID := bson.NewObjectID().Hex()
+		createdBy := ""
+		if err := reviewHistoryCollection.FindOne(ctx, historyFilter, historyOptions).Decode(&entry); err == nil {
+			createdBy = entry.AuthorID
+			if exists, err := utils.DocumentExists(ctx, reviewJobCollection, bson.M{"job_id": entry.JobID}); err == nil && !exists {
+				jobID = entry.JobID
+			}
+		}
+
+		if _, err := EnqueueReviewJob(ctx, jobID, movie.ImdbID, movie.AdminReview, createdBy); err != nil {
+			log.Printf("Error requeuing review of %s: %v", movie.ImdbID, err)
+			continue
+		}
+		log.Printf("Queued review job %s for pending movie %s", jobID, movie.ImdbID)
+	}
+}
+
+func runReviewWorker(workerID string) {
+	pollInterval := time.Duration(utils.GetEnvInt("REVIEW_WORKER_POLL_SECONDS", 2)) * time.Second
+
+	for {
+		job, err := claimReviewJob(workerID)
+		if err != nil {
+			if !errors.Is(err, mongo.ErrNoDocuments) {
+				log.Println("Error claiming review job:", err)
+			}
+			time.Sleep(pollInterval)
+			continue
+		}
+
+		processReviewJob(job)
+	}
+}
+
+// claimReviewJob locks the next due job, or a running job whose lease has
+// expired because its worker died, and counts the attempt.
+func claimReviewJob(workerID string) (models.ReviewJob, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	filter := bson.M{"$or": bson.A{
+		bson.M{"status": models.JobStatusQueued, "next_run_at": bson.M{"$lte": now}},
+		bson.M{"status": models.JobStatusRunning, "locked_until": bson.M{"$lt": now}},
+	}}
+	update := bson.M{
+		"$set": bson.M{
+			"status":       models.JobStatusRunning,
+			"locked_by":    workerID,
+			"locked_until": now.Add(reviewJobLease()),
+			"updated_at":   now,
+		},
+		"$inc": bson.M{"attempts": 1},
+	}
+	findOptions := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "next_run_at", Value: 1}}).
+		SetReturnDocument(options.After)
+
+	var job models.ReviewJob
+	err := reviewJobCollection.FindOneAndUpdate(ctx, filter, update, findOptions).Decode(&job)
+
+	return job, err
+}
+
+func processReviewJob(job models.ReviewJob) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	// Only the movie's current review is worth ranking.
+	reviewFilter := bson.M{"imdb_id": job.ImdbID, "admin_review": job.Review, "deleted_at": nil}
+
+	var movie models.Movie
+	if err := movieCollection.FindOne(ctx, reviewFilter).Decode(&movie); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			updateReviewHistoryRanking(job.JobID, bson.M{"ranking_status": ReviewJobSuperseded})
+			finishReviewJob(job, bson.M{"status": ReviewJobSuperseded})
+		} else {
+			retryReviewJob(job, err)
+		}
+		return
+	}
+
+	rankingResult, err := GetReviewRanking(movie, job.CreatedBy)
+	if errors.Is(err, ErrLLMBudgetExceeded) {
+		deferReviewJob(job, err)
+		return
+	}
+	if err != nil {
+		retryReviewJob(job, err)
+		return
+	}
+
+	ranked := bson.M{
+		"ranking":        rankingResult.Ranking,
+		"ranking_status": models.RankingStatusRanked,
+		"ranking_source": rankingSource(rankingResult),
+	}
+
+	_, err = movieCollection.UpdateOne(ctx, reviewFilter, bson.M{"$set": ranked, "$inc": bson.M{"version": 1}})
+	if err != nil {
+		retryReviewJob(job, err)
+		return
+	}
+
+	updateReviewHistoryRanking(job.JobID, ranked)
+
+	log.Printf("Review job %s ranked %s as %s", job.JobID, job.ImdbID, rankingResult.Ranking.RankingName)
+
+	finishReviewJob(job, bson.M{
+		"status": models.JobStatusCompleted,
+		"result": models.ReviewJobResult{
+			Ranking:       rankingResult.Ranking,
+			Provider:      rankingResult.Provider,
+			Model:         rankingResult.Model,
+			PromptVersion: rankingResult.PromptVersion,
+		},
+	})
+}
+
+// retryReviewJob puts the job back in the queue with an exponential backoff,
+// or dead-letters it and marks the movie's ranking as failed once it has run
+// out of attempts.
+func retryReviewJob(job models.ReviewJob, jobErr error) {
+	log.Printf("Review job %s attempt %d/%d failed: %v", job.JobID, job.Attempts, job.MaxAttempts, jobErr)
+
+	if job.Attempts >= job.MaxAttempts {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		_, err := movieCollection.UpdateOne(
+			ctx,
+			bson.M{"imdb_id": job.ImdbID, "admin_review": job.Review, "ranking_status": models.RankingStatusPending},
+			bson.M{"$set": bson.M{"ranking_status": models.RankingStatusFailed}, "$inc": bson.M{"version": 1}},
+		)
+		if err != nil {
+			log.Printf("Error marking ranking of %s as failed: %v", job.ImdbID, err)
+		}
+
+		updateReviewHistoryRanking(job.JobID, bson.M{"ranking_status": models.RankingStatusFailed})
+		finishReviewJob(job, bson.M{"status": models.JobStatusDead, "last_error": jobErr.Error()})
+		return
+	}
+
+	updateReviewJob(job, bson.M{
+		"$set": bson.M{
+			"status":      models.JobStatusQueued,
+			"next_run_at": time.Now().Add(reviewJobBackoff(job.Attempts)),
+			"last_error":  jobErr.Error(),
+			"updated_at":  time.Now(),
+		},
+		"$unset": bson.M{"locked_by": "", "locked_until": ""},
+	})
+}
+
+// deferReviewJob puts the job back until the daily LLM budget resets at
+// midnight UTC, without counting the attempt.
+func deferReviewJob(job models.ReviewJob, jobErr error) {
+	updateReviewJob(job, bson.M{
+		"$set": bson.M{
+			"status":      models.JobStatusQueued,
+			"next_run_at": startOfDay(time.Now()).AddDate(0, 0, 1),
+			"last_error":  jobErr.Error(),
+			"updated_at":  time.Now(),
+		},
+		"$unset": bson.M{"locked_by": "", "locked_until": ""},
+		"$inc":   bson.M{"attempts": -1},
+	})
+}
+
+func finishReviewJob(job models.ReviewJob, fields bson.M) {
+	fields["updated_at"] = time.Now()
+	fields["finished_at"] = time.Now()
+
+	updateReviewJob(job, bson.M{"$set": fields, "$unset": bson.M{"locked_by": "", "locked_until": ""}})
+}
+
+// updateReviewJob only touches the job while this attempt still holds it, so
+// a worker whose lease expired cannot overwrite the job's new owner.
+func updateReviewJob(job models.ReviewJob, update bson.M) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	filter := bson.M{"_id": job.ID, "status": models.JobStatusRunning, "locked_by": job.LockedBy, "attempts": job.Attempts}
+
+	result, err := reviewJobCollection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		log.Printf("Error updating review job %s: %v", job.JobID, err)
+		return
+	}
+	if result.MatchedCount == 0 {
+		log.Printf("Review job %s was reclaimed by another worker", job.JobID)
+	}
+}
@@ -0,0 +1,284 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Neph-dev/MovieStreamServer/models"
+	"github.com/Neph-dev/MovieStreamServer/utils"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+const snippetLength = 160
+const fuzzySimilarityThreshold = 0.3
+
+var movieSearchIndexOnce sync.Once
+
+type movieSearchHit struct {
+	models.Movie `bson:",inline"`
+	Score        float64 `bson:"score"`
+}
+
+func ensureMovieSearchIndex(ctx context.Context) {
+	movieSearchIndexOnce.Do(func() {
+		_, err := movieCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys: bson.D{{Key: "title", Value: "text"}, {Key: "admin_review", Value: "text"}},
+			Options: options.Index().
+				SetName("movie_text_search").
+				SetWeights(bson.D{{Key: "title", Value: 10}, {Key: "admin_review", Value: 2}}),
+		})
+		if err != nil {
+			log.Println("Warning: could not create movie text index:", err)
+		}
+	})
+}
+
+func fuzzyScanLimit() int64 {
+	var limit int64 = 5000
+
+	if value := os.Getenv("SEARCH_FUZZY_SCAN_LIMIT"); value != "" {
+		if parsed, err := strconv.ParseInt(value, 10, 64); err == nil && parsed > 0 {
+			limit = parsed
+		}
+	}
+
+	return limit
+}
+
+func SearchMovies() gin.HandlerFunc {
+	return func(_context *gin.Context) {
+		query := strings.TrimSpace(_context.Query("q"))
+		if query == "" {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": "q is required"})
+			return
+		}
+
+		filter, err := BuildMovieFilter(_context)
+		if err != nil {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		if _context.Query("mode") == "autocomplete" {
+			limit, err := utils.GetLimitParam(_context, 10, 50)
+			if err != nil {
+				_context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+
+			suggestions, err := autocompleteTitles(ctx, filter, query, limit)
+			if err != nil {
+				_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching title suggestions from database"})
+				return
+			}
+
+			_context.JSON(http.StatusOK, gin.H{"suggestions": suggestions})
+			return
+		}
+
+		limit, err := utils.GetLimitParam(_context, 20, 100)
+		if err != nil {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		terms := utils.SearchTerms(query)
+
+		results, err := textSearchMovies(ctx, filter, query, terms, limit)
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error searching movies in database"})
+			return
+		}
+
+		fuzzy := false
+		if len(results) == 0 {
+			fuzzy = true
+
+			results, err = fuzzySearchMovies(ctx, filter, query, terms, limit)
+			if err != nil {
+				_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error searching movies in database"})
+				return
+			}
+		}
+
+		_context.JSON(http.StatusOK, gin.H{
+			"query": query,
+			"fuzzy": fuzzy,
+			"data":  results,
+		})
+	}
+}
+
+func textSearchMovies(ctx context.Context, filter bson.M, query string, terms []string, limit int64) ([]models.MovieSearchResult, error) {
+	ensureMovieSearchIndex(ctx)
+
+	textFilter := bson.M{"$text": bson.M{"$search": query}}
+	for key, value := range filter {
+		textFilter[key] = value
+	}
+
+	findOptions := options.Find().
+		SetProjection(bson.M{"score": bson.M{"$meta": "textScore"}}).
+		SetSort(bson.D{{Key: "score", Value: bson.M{"$meta": "textScore"}}}).
+		SetLimit(limit)
+
+	cursor, err := movieCollection.Find(ctx, textFilter, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var hits []movieSearchHit
+	if err = cursor.All(ctx, &hits); err != nil {
+		return nil, err
+	}
+
+	results := []models.MovieSearchResult{}
+	for _, hit := range hits {
+		results = append(results, models.MovieSearchResult{
+			Movie:      hit.Movie,
+			Score:      hit.Score,
+			Highlights: movieHighlights(hit.Movie, terms),
+		})
+	}
+
+	return results, nil
+}
+
+// fuzzySearchMovies is the typo-tolerant fallback used when the text index
+// finds nothing. It scores titles by trigram similarity and by how many query
+// terms are within a small edit distance of a title word.
+func fuzzySearchMovies(ctx context.Context, filter bson.M, query string, terms []string, limit int64) ([]models.MovieSearchResult, error) {
+	cursor, err := movieCollection.Find(ctx, filter, options.Find().SetLimit(fuzzyScanLimit()))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	results := []models.MovieSearchResult{}
+
+	for cursor.Next(ctx) {
+		var movie models.Movie
+		if err := cursor.Decode(&movie); err != nil {
+			return nil, err
+		}
+
+		score := utils.TrigramSimilarity(query, movie.Title)
+		matches := utils.FuzzyWordMatches(movie.Title, terms)
+
+		if len(terms) > 0 {
+			wordScore := float64(len(matches)) / float64(len(terms))
+			if wordScore > score {
+				score = wordScore
+			}
+		}
+
+		if score < fuzzySimilarityThreshold {
+			continue
+		}
+
+		highlights := map[string]string{}
+		if title := utils.Highlight(movie.Title, matches, 0); title != "" {
+			highlights["title"] = title
+		}
+
+		results = append(results, models.MovieSearchResult{
+			Movie:      movie,
+			Score:      score,
+			Highlights: highlights,
+		})
+	}
+
+	if err := cursor.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+
+	if int64(len(results)) > limit {
+		results = results[:limit]
+	}
+
+	return results, nil
+}
+
+func movieHighlights(movie models.Movie, terms []string) map[string]string {
+	highlights := map[string]string{}
+
+	if title := utils.Highlight(movie.Title, terms, 0); title != "" {
+		highlights["title"] = title
+	}
+
+	if review := utils.Highlight(movie.AdminReview, terms, snippetLength); review != "" {
+		highlights["admin_review"] = review
+	}
+
+	return highlights
+}
+
+// autocompleteTitles suggests titles that have a word starting with prefix,
+// putting titles that start with it first.
+func autocompleteTitles(ctx context.Context, filter bson.M, prefix string, limit int64) ([]string, error) {
+	titleFilter := bson.M{"title": bson.M{"$regex": `(^|\s)` + regexp.QuoteMeta(prefix), "$options": "i"}}
+	for key, value := range filter {
+		if key != "title" {
+			titleFilter[key] = value
+		}
+	}
+
+	findOptions := options.Find().
+		SetProjection(bson.M{"title": 1}).
+		SetSort(bson.D{{Key: "title", Value: 1}}).
+		SetLimit(limit * 5)
+
+	cursor, err := movieCollection.Find(ctx, titleFilter, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var movies []models.Movie
+	if err = cursor.All(ctx, &movies); err != nil {
+		return nil, err
+	}
+
+	lowerPrefix := strings.ToLower(prefix)
+
+	sort.SliceStable(movies, func(i, j int) bool {
+		return strings.HasPrefix(strings.ToLower(movies[i].Title), lowerPrefix) &&
+			!strings.HasPrefix(strings.ToLower(movies[j].Title), lowerPrefix)
+	})
+
+	suggestions := []string{}
+	seen := map[string]bool{}
+
+	for _, movie := range movies {
+		if seen[movie.Title] {
+			continue
+		}
+		seen[movie.Title] = true
+		suggestions = append(suggestions, movie.Title)
+
+		if int64(len(suggestions)) == limit {
+			break
+		}
+	}
+
+	return suggestions, nil
+}
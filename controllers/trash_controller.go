@@ -0,0 +1,360 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/Neph-dev/MovieStreamServer/models"
+	"github.com/Neph-dev/MovieStreamServer/utils"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+type trashTarget struct {
+	collection *mongo.Collection
+	key        string
+	projection bson.M
+	onRestore  func() bson.M
+	// onPurge removes what belongs to the document before it is deleted for
+	// good, so a failure leaves the document in the trash to be retried.
+	onPurge func(ctx context.Context, id string) error
+}
+
+var trashTargets = map[string]trashTarget{
+	"movies": {
+		collection: movieCollection,
+		key:        "imdb_id",
+		onRestore: func() bson.M {
+			return bson.M{"$inc": bson.M{"version": 1}}
+		},
+		onPurge: purgeMovieData,
+	},
+	"users": {
+		collection: userCollection,
+		key:        "user_id",
+		projection: bson.M{"password": 0, "token": 0, "refresh_token": 0, "token_family": 0},
+		onRestore: func() bson.M {
+			return bson.M{"$set": bson.M{"updated_at": time.Now()}}
+		},
+		onPurge: purgeUserData,
+	},
+}
+
+// purgeMovieData removes a movie's user reviews, review history, watch
+// progress and playback events, and takes it off every watchlist.
+func purgeMovieData(ctx context.Context, imdbID string) error {
+	filter := bson.M{"imdb_id": imdbID}
+
+	for _, collection := range []*mongo.Collection{userReviewCollection, reviewHistoryCollection, watchProgressCollection, playbackEventCollection} {
+		if _, err := collection.DeleteMany(ctx, filter); err != nil {
+			return err
+		}
+	}
+
+	_, err := watchlistCollection.UpdateMany(
+		ctx,
+		bson.M{"items.imdb_id": imdbID},
+		bson.M{"$pull": bson.M{"items": filter}, "$set": bson.M{"updated_at": time.Now()}},
+	)
+	return err
+}
+
+// purgeUserData removes a user's reviews, taking their ratings out of the
+// movies' aggregates, and the user's watchlist, watch progress, playback
+// events and password reset tokens.
+func purgeUserData(ctx context.Context, userID string) error {
+	cursor, err := userReviewCollection.Find(ctx, bson.M{"user_id": userID})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var review models.UserReview
+		if err := cursor.Decode(&review); err != nil {
+			return err
+		}
+
+		err := withTransaction(ctx, func(ctx context.Context) error {
+			result, err := userReviewCollection.DeleteOne(ctx, bson.M{"_id": review.ID})
+			if err != nil || result.DeletedCount == 0 {
+				return err
+			}
+
+			err = applyRatingChange(ctx, bson.M{"imdb_id": review.ImdbID}, review.CountedRating(), 0)
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				return nil
+			}
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return err
+	}
+
+	filter := bson.M{"user_id": userID}
+	for _, collection := range []*mongo.Collection{watchlistCollection, watchProgressCollection, playbackEventCollection, passwordResetCollection} {
+		if _, err := collection.DeleteMany(ctx, filter); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// purgeTrashed permanently deletes the trashed documents of target that match
+// filter, each after its onPurge cascade.
+func purgeTrashed(ctx context.Context, target trashTarget, filter bson.M) (int64, error) {
+	cursor, err := target.collection.Find(ctx, filter, options.Find().SetProjection(bson.M{target.key: 1}))
+	if err != nil {
+		return 0, err
+	}
+
+	var documents []bson.M
+	if err := cursor.All(ctx, &documents); err != nil {
+		return 0, err
+	}
+
+	var purged int64
+	for _, document := range documents {
+		id, _ := document[target.key].(string)
+
+		if err := target.onPurge(ctx, id); err != nil {
+			return purged, err
+		}
+
+		result, err := target.collection.DeleteOne(ctx, bson.M{target.key: id, "deleted_at": bson.M{"$ne": nil}})
+		if err != nil {
+			return purged, err
+		}
+		purged += result.DeletedCount
+	}
+
+	return purged, nil
+}
+
+func trashRetention() time.Duration {
+	days := 30
+
+	if value := os.Getenv("TRASH_RETENTION_DAYS"); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil && parsed >= 0 {
+			days = parsed
+		}
+	}
+
+	return time.Duration(days) * 24 * time.Hour
+}
+
+func trashSweepInterval() time.Duration {
+	minutes := 60
+
+	if value := os.Getenv("TRASH_SWEEP_INTERVAL_MINUTES"); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil && parsed > 0 {
+			minutes = parsed
+		}
+	}
+
+	return time.Duration(minutes) * time.Minute
+}
+
+func getTrashTarget(_context *gin.Context) (trashTarget, bool) {
+	target, ok := trashTargets[_context.Param("kind")]
+	if !ok {
+		_context.JSON(http.StatusNotFound, gin.H{"error": "Unknown trash kind, expected movies or users"})
+	}
+
+	return target, ok
+}
+
+func ListTrash() gin.HandlerFunc {
+	return func(_context *gin.Context) {
+		target, ok := getTrashTarget(_context)
+		if !ok {
+			return
+		}
+
+		limit, err := utils.GetLimitParam(_context, 50, 200)
+		if err != nil {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		page, err := strconv.ParseInt(_context.DefaultQuery("page", "1"), 10, 64)
+		if err != nil || page < 1 {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": "page must be a positive integer"})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		filter := bson.M{"deleted_at": bson.M{"$ne": nil}}
+
+		total, err := target.collection.CountDocuments(ctx, filter)
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error counting trashed items"})
+			return
+		}
+
+		findOptions := options.Find().
+			SetSort(bson.D{{Key: "deleted_at", Value: -1}}).
+			SetSkip((page - 1) * limit).
+			SetLimit(limit)
+		if target.projection != nil {
+			findOptions.SetProjection(target.projection)
+		}
+
+		cursor, err := target.collection.Find(ctx, filter, findOptions)
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching trashed items"})
+			return
+		}
+		defer cursor.Close(ctx)
+
+		items := []bson.M{}
+		if err = cursor.All(ctx, &items); err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error decoding trashed items"})
+			return
+		}
+
+		_context.JSON(http.StatusOK, gin.H{
+			"data": items,
+			"pagination": utils.Pagination{
+				Limit:      limit,
+				Page:       page,
+				Total:      &total,
+				TotalPages: (total + limit - 1) / limit,
+				HasMore:    page*limit < total,
+			},
+			"retention_days": int(trashRetention().Hours() / 24),
+		})
+	}
+}
+
+func RestoreTrashItem() gin.HandlerFunc {
+	return func(_context *gin.Context) {
+		target, ok := getTrashTarget(_context)
+		if !ok {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		update := bson.M{"$unset": bson.M{"deleted_at": "", "deleted_by": ""}}
+		for operator, fields := range target.onRestore() {
+			update[operator] = fields
+		}
+
+		result, err := target.collection.UpdateOne(
+			ctx,
+			bson.M{target.key: _context.Param("id"), "deleted_at": bson.M{"$ne": nil}},
+			update,
+		)
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error restoring item"})
+			return
+		}
+		if result.MatchedCount == 0 {
+			_context.JSON(http.StatusNotFound, gin.H{"error": "Item not found in trash"})
+			return
+		}
+
+		_context.JSON(http.StatusOK, gin.H{"message": "Item restored successfully"})
+	}
+}
+
+func PurgeTrashItem() gin.HandlerFunc {
+	return func(_context *gin.Context) {
+		target, ok := getTrashTarget(_context)
+		if !ok {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		purged, err := purgeTrashed(ctx, target, bson.M{target.key: _context.Param("id"), "deleted_at": bson.M{"$ne": nil}})
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error purging item"})
+			return
+		}
+		if purged == 0 {
+			_context.JSON(http.StatusNotFound, gin.H{"error": "Item not found in trash"})
+			return
+		}
+
+		_context.JSON(http.StatusOK, gin.H{"message": "Item permanently deleted"})
+	}
+}
+
+func PurgeExpiredTrash() gin.HandlerFunc {
+	return func(_context *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		purged, err := purgeExpiredTrash(ctx)
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error purging trash"})
+			return
+		}
+
+		_context.JSON(http.StatusOK, gin.H{"message": "Trash purged", "purged": purged})
+	}
+}
+
+// purgeExpiredTrash permanently removes every soft-deleted document that has
+// been in the trash for longer than the retention period, along with what
+// belongs to it.
+func purgeExpiredTrash(ctx context.Context) (map[string]int64, error) {
+	cutoff := time.Now().Add(-trashRetention())
+	purged := map[string]int64{}
+
+	for kind, target := range trashTargets {
+		count, err := purgeTrashed(ctx, target, bson.M{"deleted_at": bson.M{"$ne": nil, "$lte": cutoff}})
+		purged[kind] = count
+		if err != nil {
+			return purged, err
+		}
+	}
+
+	return purged, nil
+}
+
+// StartTrashSweeper runs purgeExpiredTrash in the background every
+// TRASH_SWEEP_INTERVAL_MINUTES.
+func StartTrashSweeper() {
+	interval := trashSweepInterval()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			ctx, cancel := context.WithTimeout(context.Background(), interval)
+			purged, err := purgeExpiredTrash(ctx)
+			cancel()
+
+			if err != nil {
+				log.Println("Error purging trash:", err)
+				continue
+			}
+
+			for kind, count := range purged {
+				if count > 0 {
+					log.Printf("Purged %d %s from trash", count, kind)
+				}
+			}
+		}
+	}()
+}
@@ -2,6 +2,7 @@ package controllers
 
 import (
 	"context"
+	"log"
 	"net/http"
 	"time"
 
@@ -34,6 +35,10 @@ func RegisterUser () gin.HandlerFunc {
 			return
 		}
 
+		// Self-registration always creates a plain user; other roles are
+		// granted by an admin through UpdateUserRole.
+		user.Role = model.RoleUser
+
 		var validate = validator.New()
 
 		if err := validate.Struct(user); err != nil {
@@ -51,6 +56,12 @@ func RegisterUser () gin.HandlerFunc {
 		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 		defer cancel()
 
+		user.FavouriteGenres, err = ResolveGenres(ctx, user.FavouriteGenres)
+		if err != nil {
+			respondGenreError(_context, err)
+			return
+		}
+
 		if exists, err := utils.DocumentExists(ctx, userCollection, bson.M{"email": user.Email}); err != nil {
 			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking for existing user"})
 			return
@@ -62,13 +73,22 @@ func RegisterUser () gin.HandlerFunc {
 		user.UserID = bson.NewObjectID().Hex()
 		user.CreatedAt = time.Now()
 		user.UpdatedAt = time.Now()
+		user.DeletedAt = nil
+		user.DeletedBy = ""
+		user.EmailVerified = false
 
 		if err := utils.InsertDocument(ctx, userCollection, user); err != nil {
 			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error inserting user into database"})
 			return
 		}
 
-		_context.JSON(http.StatusOK, gin.H{"message": "User registered successfully"})
+		// The account exists either way; a failed send can be retried
+		// through the resend endpoint.
+		if err := sendVerificationEmail(ctx, user); err != nil {
+			log.Println("Error sending verification email to user", user.UserID+":", err)
+		}
+
+		_context.JSON(http.StatusOK, gin.H{"message": "User registered successfully, check your email to verify your address"})
 	}
 }
 
@@ -92,7 +112,7 @@ func LoginUser() gin.HandlerFunc {
 		defer cancel()
 
 		var user model.User
-		err := userCollection.FindOne(ctx, bson.M{"email": userLogin.Email}).Decode(&user)
+		err := userCollection.FindOne(ctx, utils.ActiveFilter(bson.M{"email": userLogin.Email})).Decode(&user)
 		if err != nil {
 			_context.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
 			return
@@ -104,21 +124,22 @@ func LoginUser() gin.HandlerFunc {
 			return
 		}
 
-		token, refreshToken, err := utils.GenerateAllTokens(user.Email, user.FirstName, user.LastName, user.UserID, user.Role)
-		if err != nil {
-			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error generating tokens"})
+		if !user.EmailVerified && utils.UnverifiedUserAccess() == utils.UnverifiedAccessNone {
+			_context.JSON(http.StatusForbidden, gin.H{"error": "Email address is not verified", "code": "EMAIL_NOT_VERIFIED"})
 			return
 		}
 
-		user.Token = token
-		user.RefreshToken = refreshToken
+		family := utils.NewTokenFamily()
 
-		err = utils.UpdateTokens(token, refreshToken, user.UserID)
+		token, refreshToken, err := utils.GenerateAllTokens(user.Email, user.FirstName, user.LastName, user.UserID, user.Role, family, user.EmailVerified)
 		if err != nil {
-			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating tokens"})
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error generating tokens"})
 			return
 		}
 
+		user.Token = token
+		user.RefreshToken = refreshToken
+
 		_context.JSON(http.StatusOK, gin.H{
 			"message": "Login successful",
 			"st-access-token": user.Token,
@@ -129,5 +150,213 @@ func LoginUser() gin.HandlerFunc {
 	}
 }
 
+func RefreshToken() gin.HandlerFunc {
+	return func(_context *gin.Context) {
+		var refreshRequest model.RefreshRequest
+		if err := _context.BindJSON(&refreshRequest); err != nil {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+
+		var validate = validator.New()
+		if err := validate.Struct(refreshRequest); err != nil {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "details": err.Error()})
+			return
+		}
+
+		claims, err := utils.ValidateRefreshToken(refreshRequest.RefreshToken)
+		if err != nil {
+			_context.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: " + err.Error()})
+			return
+		}
+
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		var user model.User
+		err = userCollection.FindOne(ctx, utils.ActiveFilter(bson.M{"user_id": claims.UID})).Decode(&user)
+		if err != nil {
+			_context.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: user not found"})
+			return
+		}
+
+		// A token from an older, superseded login is simply stale. A token from
+		// the current family that is no longer the stored one has already been
+		// rotated, so someone is replaying it and the whole family is revoked.
+		if claims.Family == "" || claims.Family != user.TokenFamily {
+			_context.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: refresh token is no longer valid"})
+			return
+		}
+
+		token, refreshToken, err := utils.SignAllTokens(user.Email, user.FirstName, user.LastName, user.UserID, user.Role, user.TokenFamily, user.EmailVerified)
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error generating tokens"})
+			return
+		}
+
+		rotated, err := utils.RotateTokens(refreshRequest.RefreshToken, token, refreshToken, user.UserID)
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating tokens"})
+			return
+		}
+
+		if !rotated {
+			if err := utils.RevokeAllUserTokens(user.UserID); err != nil {
+				_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error revoking tokens"})
+				return
+			}
+
+			_context.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: refresh token reuse detected, all sessions revoked"})
+			return
+		}
+
+		_context.JSON(http.StatusOK, gin.H{
+			"message": "Token refreshed successfully",
+			"st-access-token": token,
+			"st-refresh-token": refreshToken,
+		})
+	}
+}
+
+func LogoutUser() gin.HandlerFunc {
+	return func(_context *gin.Context) {
+		userId, err := utils.GetDataFromContext(_context, "userId")
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving user data from context"})
+			return
+		}
+
+		tokenId, err := utils.GetDataFromContext(_context, "tokenId")
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving token data from context"})
+			return
+		}
 
+		family, err := utils.GetDataFromContext(_context, "tokenFamily")
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving token data from context"})
+			return
+		}
 
+		if err := utils.RevokeToken(tokenId, userId, time.Now().Add(utils.AccessTokenTTL)); err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error revoking token"})
+			return
+		}
+
+		if err := utils.EndSession(userId, family); err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error ending session"})
+			return
+		}
+
+		_context.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+	}
+}
+
+func LogoutAllSessions() gin.HandlerFunc {
+	return func(_context *gin.Context) {
+		userId, err := utils.GetDataFromContext(_context, "userId")
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving user data from context"})
+			return
+		}
+
+		if err := utils.RevokeAllUserTokens(userId); err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error revoking tokens"})
+			return
+		}
+
+		_context.JSON(http.StatusOK, gin.H{"message": "Logged out of all sessions successfully"})
+	}
+}
+
+func DeleteUser() gin.HandlerFunc {
+	return func(_context *gin.Context) {
+		adminId, err := utils.GetDataFromContext(_context, "userId")
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving user data from context"})
+			return
+		}
+
+		userId := _context.Param("user_id")
+
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		result, err := userCollection.UpdateOne(
+			ctx,
+			utils.ActiveFilter(bson.M{"user_id": userId}),
+			bson.M{"$set": bson.M{"deleted_at": time.Now(), "deleted_by": adminId, "updated_at": time.Now()}},
+		)
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error deleting user"})
+			return
+		}
+		if result.MatchedCount == 0 {
+			_context.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+
+		if err := utils.RevokeAllUserTokens(userId); err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error revoking user tokens"})
+			return
+		}
+
+		_context.JSON(http.StatusOK, gin.H{"message": "User moved to trash"})
+	}
+}
+
+// UpdateUserRole grants a role to a user. The role is carried in the user's
+// tokens, so they are revoked and the new role applies from the next login.
+// Admins cannot change their own role, so the last admin cannot lock
+// everyone out by accident. The first admin has to be set in the database.
+func UpdateUserRole() gin.HandlerFunc {
+	return func(_context *gin.Context) {
+		adminId, err := utils.GetDataFromContext(_context, "userId")
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving user data from context"})
+			return
+		}
+
+		userId := _context.Param("user_id")
+		if userId == adminId {
+			_context.JSON(http.StatusForbidden, gin.H{"error": "You cannot change your own role"})
+			return
+		}
+
+		var request model.RoleUpdate
+		if err := _context.BindJSON(&request); err != nil {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+
+		var validate = validator.New()
+		if err := validate.Struct(request); err != nil {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed", "details": err.Error()})
+			return
+		}
+
+		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		result, err := userCollection.UpdateOne(
+			ctx,
+			utils.ActiveFilter(bson.M{"user_id": userId}),
+			bson.M{"$set": bson.M{"role": request.Role, "updated_at": time.Now()}},
+		)
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating user role"})
+			return
+		}
+		if result.MatchedCount == 0 {
+			_context.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+
+		if err := utils.RevokeAllUserTokens(userId); err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error revoking user tokens"})
+			return
+		}
+
+		_context.JSON(http.StatusOK, gin.H{"message": "User role updated", "user_id": userId, "role": request.Role})
+	}
+}
@@ -0,0 +1,371 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Neph-dev/MovieStreamServer/models"
+	"github.com/Neph-dev/MovieStreamServer/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// userReviewCollection shares movieCollection's client so that both can take
+// part in the same transaction.
+var userReviewCollection *mongo.Collection = movieCollection.Database().Collection("user_reviews")
+
+var userReviewIndexesOnce sync.Once
+
+var userReviewSorts = map[string]bson.D{
+	"recent":      {{Key: "updated_at", Value: -1}, {Key: "_id", Value: -1}},
+	"rating_high": {{Key: "rating", Value: -1}, {Key: "updated_at", Value: -1}},
+	"rating_low":  {{Key: "rating", Value: 1}, {Key: "updated_at", Value: -1}},
+}
+
+func ensureUserReviewIndexes(ctx context.Context) {
+	userReviewIndexesOnce.Do(func() {
+		_, err := userReviewCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+			{Keys: bson.D{{Key: "imdb_id", Value: 1}, {Key: "user_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+			{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "updated_at", Value: -1}}},
+		})
+		if err != nil {
+			log.Println("Warning: could not create user review indexes:", err)
+		}
+	})
+}
+
+// ratingPrior is the mean and weight, in ratings, of the prior the Bayesian
+// score starts from.
+func ratingPrior() (float64, float64) {
+	mean, weight := 5.5, 10.0
+
+	if value, err := strconv.ParseFloat(os.Getenv("RATING_PRIOR_MEAN"), 64); err == nil && value >= 1 && value <= 10 {
+		mean = value
+	}
+	if value, err := strconv.ParseFloat(os.Getenv("RATING_PRIOR_WEIGHT"), 64); err == nil && value >= 0 {
+		weight = value
+	}
+
+	return mean, weight
+}
+
+// applyRatingChange moves the rating aggregate of the movie matched by
+// movieFilter from previous to current, either of which is 0 when there is no
+// rating, and recomputes its mean and Bayesian score. It must run inside the
+// transaction that wrote the review. Only new reviews need an active movie;
+// changes to existing ones also apply to a movie in the trash, which keeps a
+// correct aggregate for when it is restored.
+func applyRatingChange(ctx context.Context, movieFilter bson.M, previous int, current int) error {
+	inc := map[string]int{}
+	if previous > 0 {
+		inc["user_rating.count"]--
+		inc["user_rating.sum"] -= previous
+		inc["user_rating.histogram."+strconv.Itoa(previous)]--
+	}
+	if current > 0 {
+		inc["user_rating.count"]++
+		inc["user_rating.sum"] += current
+		inc["user_rating.histogram."+strconv.Itoa(current)]++
+	}
+
+	var movie models.Movie
+
+	if len(inc) == 0 {
+		// Nothing to count, but the movie must still exist.
+		return movieCollection.FindOne(ctx, movieFilter).Decode(&movie)
+	}
+
+	err := movieCollection.FindOneAndUpdate(
+		ctx,
+		movieFilter,
+		bson.M{"$inc": inc},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&movie)
+	if err != nil {
+		return err
+	}
+
+	aggregate := movie.UserRating
+	if aggregate == nil || aggregate.Count <= 0 {
+		_, err = movieCollection.UpdateOne(ctx, bson.M{"_id": movie.ID}, bson.M{"$unset": bson.M{"user_rating": ""}})
+		return err
+	}
+
+	priorMean, priorWeight := ratingPrior()
+	count, sum := float64(aggregate.Count), float64(aggregate.Sum)
+
+	_, err = movieCollection.UpdateOne(ctx, bson.M{"_id": movie.ID}, bson.M{"$set": bson.M{
+		"user_rating.mean":           sum / count,
+		"user_rating.bayesian_score": (priorWeight*priorMean + sum) / (priorWeight + count),
+	}})
+	return err
+}
+
+// withTransaction runs fn in a transaction on movieCollection's client, which
+// needs MongoDB to run as a replica set.
+func withTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	session, err := movieCollection.Database().Client().StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(ctx context.Context) (interface{}, error) {
+		return nil, fn(ctx)
+	})
+	return err
+}
+
+// SaveUserReview creates or replaces the current user's rating and review of
+// a movie and updates the movie's rating aggregate in the same transaction.
+// Reviews with text go through moderation before they are public or counted.
+func SaveUserReview() gin.HandlerFunc {
+	return func(_context *gin.Context) {
+		imdbID := _context.Param("imdb_id")
+
+		userId, err := utils.GetDataFromContext(_context, "userId")
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving user data from context"})
+			return
+		}
+		firstName, _ := utils.GetDataFromContext(_context, "first_name")
+		lastName, _ := utils.GetDataFromContext(_context, "last_name")
+
+		var request struct {
+			Rating int    `json:"rating" validate:"required,min=1,max=10"`
+			Review string `json:"review" validate:"max=5000"`
+		}
+
+		if err := _context.BindJSON(&request); err != nil {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+
+		var validate = validator.New()
+		if err := validate.Struct(request); err != nil {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		userName := strings.TrimSpace(firstName)
+		if initial := []rune(strings.TrimSpace(lastName)); len(initial) > 0 {
+			userName += " " + string(initial[0]) + "."
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		ensureUserReviewIndexes(ctx)
+
+		var review models.UserReview
+		created := false
+
+		err = withTransaction(ctx, func(ctx context.Context) error {
+			var previous models.UserReview
+			err := userReviewCollection.FindOne(ctx, bson.M{"imdb_id": imdbID, "user_id": userId}).Decode(&previous)
+			if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+				return err
+			}
+			created = errors.Is(err, mongo.ErrNoDocuments)
+
+			now := time.Now()
+			review = models.UserReview{
+				ID:        previous.ID,
+				ImdbID:    imdbID,
+				UserID:    userId,
+				UserName:  userName,
+				Rating:    request.Rating,
+				Review:    strings.TrimSpace(request.Review),
+				CreatedAt: now,
+				UpdatedAt: now,
+			}
+			if !created {
+				review.CreatedAt = previous.CreatedAt
+			}
+
+			// A review whose text did not change keeps its moderation
+			// outcome; anything else is moderated afresh.
+			if !created && previous.Review == review.Review && previous.Moderation != nil {
+				review.Moderation = previous.Moderation
+			} else {
+				review.Moderation = initialModeration(review.Review)
+			}
+
+			if err := applyRatingChange(ctx, utils.ActiveFilter(bson.M{"imdb_id": imdbID}), previous.CountedRating(), review.CountedRating()); err != nil {
+				return err
+			}
+
+			if created {
+				result, err := userReviewCollection.InsertOne(ctx, review)
+				if err != nil {
+					return err
+				}
+				review.ID = result.InsertedID.(bson.ObjectID)
+				return nil
+			}
+
+			_, err = userReviewCollection.ReplaceOne(ctx, bson.M{"_id": previous.ID}, review)
+			return err
+		})
+
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			_context.JSON(http.StatusNotFound, gin.H{"error": "Movie not found"})
+			return
+		}
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error saving review"})
+			return
+		}
+
+		if review.Moderation.Status == models.ModerationPending {
+			go moderateUserReview(review)
+		}
+
+		status := http.StatusOK
+		if created {
+			status = http.StatusCreated
+		}
+
+		_context.JSON(status, review)
+	}
+}
+
+func DeleteUserReview() gin.HandlerFunc {
+	return func(_context *gin.Context) {
+		imdbID := _context.Param("imdb_id")
+
+		userId, err := utils.GetDataFromContext(_context, "userId")
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving user data from context"})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		errReviewNotFound := errors.New("review not found")
+
+		err = withTransaction(ctx, func(ctx context.Context) error {
+			var review models.UserReview
+			err := userReviewCollection.FindOneAndDelete(ctx, bson.M{"imdb_id": imdbID, "user_id": userId}).Decode(&review)
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				return errReviewNotFound
+			}
+			if err != nil {
+				return err
+			}
+
+			err = applyRatingChange(ctx, bson.M{"imdb_id": imdbID}, review.CountedRating(), 0)
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				// The movie was purged; there is no aggregate left.
+				return nil
+			}
+			return err
+		})
+
+		if errors.Is(err, errReviewNotFound) {
+			_context.JSON(http.StatusNotFound, gin.H{"error": "Review not found"})
+			return
+		}
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error deleting review"})
+			return
+		}
+
+		_context.JSON(http.StatusOK, gin.H{"message": "Review deleted"})
+	}
+}
+
+// listUserReviews pages through the reviews matching filter, sorted by the
+// sort query parameter (recent, rating_high or rating_low).
+func listUserReviews(_context *gin.Context, filter bson.M) {
+	limit, err := utils.GetLimitParam(_context, 20, 100)
+	if err != nil {
+		_context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	page, err := strconv.ParseInt(_context.DefaultQuery("page", "1"), 10, 64)
+	if err != nil || page < 1 {
+		_context.JSON(http.StatusBadRequest, gin.H{"error": "page must be a positive integer"})
+		return
+	}
+
+	sort, ok := userReviewSorts[_context.DefaultQuery("sort", "recent")]
+	if !ok {
+		_context.JSON(http.StatusBadRequest, gin.H{"error": "sort must be recent, rating_high or rating_low"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	total, err := userReviewCollection.CountDocuments(ctx, filter)
+	if err != nil {
+		_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error counting reviews"})
+		return
+	}
+
+	findOptions := options.Find().
+		SetSort(sort).
+		SetSkip((page - 1) * limit).
+		SetLimit(limit)
+
+	cursor, err := userReviewCollection.Find(ctx, filter, findOptions)
+	if err != nil {
+		_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching reviews"})
+		return
+	}
+	defer cursor.Close(ctx)
+
+	reviews := []models.UserReview{}
+	if err = cursor.All(ctx, &reviews); err != nil {
+		_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error decoding reviews"})
+		return
+	}
+
+	_context.JSON(http.StatusOK, gin.H{
+		"data": reviews,
+		"pagination": utils.Pagination{
+			Limit:      limit,
+			Page:       page,
+			Total:      &total,
+			TotalPages: (total + limit - 1) / limit,
+			HasMore:    page*limit < total,
+		},
+	})
+}
+
+// GetMovieUserReviews lists a movie's approved reviews.
+func GetMovieUserReviews() gin.HandlerFunc {
+	return func(_context *gin.Context) {
+		listUserReviews(_context, bson.M{
+			"imdb_id":           _context.Param("imdb_id"),
+			"moderation.status": bson.M{"$in": bson.A{models.ModerationApproved, nil}},
+		})
+	}
+}
+
+// GetMyReviews lists the current user's reviews, whatever their moderation
+// state, so users can see which are still pending or were rejected.
+func GetMyReviews() gin.HandlerFunc {
+	return func(_context *gin.Context) {
+		userId, err := utils.GetDataFromContext(_context, "userId")
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving user data from context"})
+			return
+		}
+
+		listUserReviews(_context, bson.M{"user_id": userId})
+	}
+}
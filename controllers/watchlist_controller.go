@@ -0,0 +1,332 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	db "github.com/Neph-dev/MovieStreamServer/database"
+	"github.com/Neph-dev/MovieStreamServer/models"
+	"github.com/Neph-dev/MovieStreamServer/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+var watchlistCollection *mongo.Collection = db.OpenCollection("watchlists")
+
+var watchlistIndexesOnce sync.Once
+
+func ensureWatchlistIndexes(ctx context.Context) {
+	watchlistIndexesOnce.Do(func() {
+		_, err := watchlistCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys:    bson.D{{Key: "user_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		})
+		if err != nil {
+			log.Println("Warning: could not create watchlist indexes:", err)
+		}
+	})
+}
+
+func watchlistMaxItems() int {
+	return utils.GetEnvInt("WATCHLIST_MAX_ITEMS", 200)
+}
+
+func getWatchlist(ctx context.Context, userId string) (models.Watchlist, error) {
+	watchlist := models.Watchlist{UserID: userId, Items: []models.WatchlistItem{}}
+
+	err := watchlistCollection.FindOne(ctx, bson.M{"user_id": userId}).Decode(&watchlist)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return watchlist, nil
+	}
+
+	return watchlist, err
+}
+
+// GetWatchlistImdbIDs returns the imdb_ids on a user's watchlist.
+func GetWatchlistImdbIDs(ctx context.Context, userId string) ([]string, error) {
+	watchlist, err := getWatchlist(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	imdbIDs := make([]string, 0, len(watchlist.Items))
+	for _, item := range watchlist.Items {
+		imdbIDs = append(imdbIDs, item.ImdbID)
+	}
+
+	return imdbIDs, nil
+}
+
+// GetWatchlist lists the current user's watchlist in order, each item with
+// its movie. Movies that have since been removed stay listed as unavailable
+// until the user removes them.
+func GetWatchlist() gin.HandlerFunc {
+	return func(_context *gin.Context) {
+		userId, err := utils.GetDataFromContext(_context, "userId")
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving user data from context"})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		watchlist, err := getWatchlist(ctx, userId)
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching watchlist"})
+			return
+		}
+
+		imdbIDs := make([]string, 0, len(watchlist.Items))
+		for _, item := range watchlist.Items {
+			imdbIDs = append(imdbIDs, item.ImdbID)
+		}
+
+		movies := map[string]models.Movie{}
+		if len(imdbIDs) > 0 {
+			cursor, err := movieCollection.Find(ctx, utils.ActiveFilter(bson.M{"imdb_id": bson.M{"$in": imdbIDs}}))
+			if err != nil {
+				_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching watchlist movies"})
+				return
+			}
+			defer cursor.Close(ctx)
+
+			var found []models.Movie
+			if err := cursor.All(ctx, &found); err != nil {
+				_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error decoding watchlist movies"})
+				return
+			}
+			for _, movie := range found {
+				movies[movie.ImdbID] = movie
+			}
+		}
+
+		entries := make([]models.WatchlistEntry, 0, len(watchlist.Items))
+		for i, item := range watchlist.Items {
+			entry := models.WatchlistEntry{
+				Position: i + 1,
+				ImdbID:   item.ImdbID,
+				Note:     item.Note,
+				AddedAt:  item.AddedAt,
+			}
+			if movie, ok := movies[item.ImdbID]; ok {
+				entry.Available = true
+				entry.Movie = &movie
+			}
+			entries = append(entries, entry)
+		}
+
+		_context.JSON(http.StatusOK, gin.H{
+			"data":      entries,
+			"count":     len(entries),
+			"max_items": watchlistMaxItems(),
+		})
+	}
+}
+
+type watchlistAddRequest struct {
+	ImdbID   string `json:"imdb_id" validate:"required"`
+	Note     string `json:"note" validate:"max=500"`
+	Position int    `json:"position" validate:"min=0"`
+}
+
+// AddToWatchlist appends a movie to the current user's watchlist, or inserts
+// it at a 1-based position.
+func AddToWatchlist() gin.HandlerFunc {
+	return func(_context *gin.Context) {
+		userId, err := utils.GetDataFromContext(_context, "userId")
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving user data from context"})
+			return
+		}
+
+		var request watchlistAddRequest
+		if err := _context.BindJSON(&request); err != nil {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+
+		var validate = validator.New()
+		if err := validate.Struct(request); err != nil {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		ensureWatchlistIndexes(ctx)
+
+		exists, err := utils.DocumentExists(ctx, movieCollection, utils.ActiveFilter(bson.M{"imdb_id": request.ImdbID}))
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking for existing movie"})
+			return
+		}
+		if !exists {
+			_context.JSON(http.StatusNotFound, gin.H{"error": "Movie not found"})
+			return
+		}
+
+		push := bson.M{"$each": bson.A{models.WatchlistItem{
+			ImdbID:  request.ImdbID,
+			Note:    strings.TrimSpace(request.Note),
+			AddedAt: time.Now(),
+		}}}
+		if request.Position > 0 {
+			push["$position"] = request.Position - 1
+		}
+
+		// The filter only matches while the movie is not on the list and the
+		// list is below the cap, so concurrent adds cannot break either rule.
+		maxItems := watchlistMaxItems()
+		filter := bson.M{
+			"user_id":                           userId,
+			"items.imdb_id":                     bson.M{"$ne": request.ImdbID},
+			"items." + strconv.Itoa(maxItems-1): bson.M{"$exists": false},
+		}
+
+		_, err = watchlistCollection.UpdateOne(
+			ctx,
+			filter,
+			bson.M{"$push": bson.M{"items": push}, "$set": bson.M{"updated_at": time.Now()}},
+			options.UpdateOne().SetUpsert(true),
+		)
+		if mongo.IsDuplicateKeyError(err) {
+			// The user's watchlist exists but did not match the filter.
+			watchlist, err := getWatchlist(ctx, userId)
+			if err != nil {
+				_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error adding to watchlist"})
+				return
+			}
+			if slices.ContainsFunc(watchlist.Items, func(item models.WatchlistItem) bool { return item.ImdbID == request.ImdbID }) {
+				_context.JSON(http.StatusConflict, gin.H{"error": "Movie is already on the watchlist"})
+				return
+			}
+			_context.JSON(http.StatusConflict, gin.H{"error": "Watchlist is full (" + strconv.Itoa(maxItems) + " movies)"})
+			return
+		}
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error adding to watchlist"})
+			return
+		}
+
+		_context.JSON(http.StatusCreated, gin.H{"message": "Movie added to watchlist", "imdb_id": request.ImdbID})
+	}
+}
+
+// UpdateWatchlistItem moves a movie to a new 1-based position and/or changes
+// its note.
+func UpdateWatchlistItem() gin.HandlerFunc {
+	return func(_context *gin.Context) {
+		imdbID := _context.Param("imdb_id")
+
+		userId, err := utils.GetDataFromContext(_context, "userId")
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving user data from context"})
+			return
+		}
+
+		var request struct {
+			Position *int    `json:"position" validate:"omitempty,min=1"`
+			Note     *string `json:"note" validate:"omitempty,max=500"`
+		}
+
+		if err := _context.BindJSON(&request); err != nil {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+
+		var validate = validator.New()
+		if err := validate.Struct(request); err != nil {
+			_context.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		watchlist, err := getWatchlist(ctx, userId)
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error fetching watchlist"})
+			return
+		}
+
+		index := slices.IndexFunc(watchlist.Items, func(item models.WatchlistItem) bool { return item.ImdbID == imdbID })
+		if index < 0 {
+			_context.JSON(http.StatusNotFound, gin.H{"error": "Movie is not on the watchlist"})
+			return
+		}
+
+		items := slices.Clone(watchlist.Items)
+		item := items[index]
+		if request.Note != nil {
+			item.Note = strings.TrimSpace(*request.Note)
+		}
+
+		items = slices.Delete(items, index, index+1)
+		position := index
+		if request.Position != nil {
+			position = min(*request.Position-1, len(items))
+		}
+		items = slices.Insert(items, position, item)
+
+		// Matching updated_at makes this a compare-and-swap against any
+		// change made since the watchlist was read.
+		result, err := watchlistCollection.UpdateOne(
+			ctx,
+			bson.M{"user_id": userId, "updated_at": watchlist.UpdatedAt},
+			bson.M{"$set": bson.M{"items": items, "updated_at": time.Now()}},
+		)
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error updating watchlist"})
+			return
+		}
+		if result.MatchedCount == 0 {
+			_context.JSON(http.StatusConflict, gin.H{"error": "Watchlist was modified concurrently, retry"})
+			return
+		}
+
+		_context.JSON(http.StatusOK, gin.H{"message": "Watchlist updated", "imdb_id": imdbID, "position": position + 1})
+	}
+}
+
+func RemoveFromWatchlist() gin.HandlerFunc {
+	return func(_context *gin.Context) {
+		imdbID := _context.Param("imdb_id")
+
+		userId, err := utils.GetDataFromContext(_context, "userId")
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error retrieving user data from context"})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		defer cancel()
+
+		result, err := watchlistCollection.UpdateOne(
+			ctx,
+			bson.M{"user_id": userId, "items.imdb_id": imdbID},
+			bson.M{"$pull": bson.M{"items": bson.M{"imdb_id": imdbID}}, "$set": bson.M{"updated_at": time.Now()}},
+		)
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error removing from watchlist"})
+			return
+		}
+		if result.MatchedCount == 0 {
+			_context.JSON(http.StatusNotFound, gin.H{"error": "Movie is not on the watchlist"})
+			return
+		}
+
+		_context.JSON(http.StatusOK, gin.H{"message": "Movie removed from watchlist"})
+	}
+}
@@ -4,18 +4,34 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"testing"
 
 	dotenv "github.com/joho/godotenv"
 	"go.mongodb.org/mongo-driver/v2/mongo"
 	"go.mongodb.org/mongo-driver/v2/mongo/options"
 )
 
-func ConnectDB() *mongo.Client {
-	err := dotenv.Load()
-	
-	if err != nil {
+// loadEnv reads .env when there is one. Without it the settings must already
+// be in the environment, as in containers and integration tests.
+func loadEnv() {
+	// Unit tests of packages that open collections at startup never reach
+	// the database, so they get a local one instead of needing a .env file.
+	if testing.Testing() {
+		if os.Getenv("MONGO_URI") == "" {
+			os.Setenv("MONGO_URI", "mongodb://localhost:27017/")
+		}
+		if os.Getenv("DB_NAME") == "" {
+			os.Setenv("DB_NAME", "moviestream_test")
+		}
+	}
+
+	if err := dotenv.Load(); err != nil && os.Getenv("MONGO_URI") == "" {
 		log.Fatal("Error: Error loading .env file")
 	}
+}
+
+func ConnectDB() *mongo.Client {
+	loadEnv()
 
 	MongoDb := os.Getenv("MONGO_URI")
 
@@ -35,10 +51,7 @@ func ConnectDB() *mongo.Client {
 }
 
 func OpenCollection(collectionName string) *mongo.Collection {
-	err := dotenv.Load()
-	if err != nil {
-		log.Fatal("Error: Error loading .env file")
-	}
+	loadEnv()
 
 	dbName := os.Getenv("DB_NAME")
 	if dbName == "" {
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/Neph-dev/MovieStreamServer/controllers"
+	"github.com/Neph-dev/MovieStreamServer/utils"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// runExportCommand implements `moviestream export`, an offline backup of the
+// movies, rankings or users collection in the same formats as the admin
+// export endpoint. Documents in the trash are only included with -backup.
+func runExportCommand(args []string) int {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+
+	collection := flags.String("collection", "movies", "collection to export: movies, rankings or users")
+	format := flags.String("format", "ndjson", "output format: ndjson, csv or json")
+	output := flags.String("out", "", "output file (defaults to stdout)")
+	genre := flags.String("genre", "", "movies only: comma-separated genre names")
+	minRanking := flags.Int("min-ranking", 0, "movies only: minimum ranking value")
+	maxRanking := flags.Int("max-ranking", 0, "movies only: maximum ranking value")
+	titlePrefix := flags.String("title-prefix", "", "movies only: title prefix")
+	backup := flags.Bool("backup", false, "include movies and users that are in the trash")
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	filter := bson.M{}
+
+	switch *collection {
+	case "users":
+		filter = utils.ActiveFilter(filter)
+	case "movies":
+		query := url.Values{}
+		if *genre != "" {
+			query.Set("genre", *genre)
+		}
+		if *minRanking != 0 {
+			query.Set("min_ranking", strconv.Itoa(*minRanking))
+		}
+		if *maxRanking != 0 {
+			query.Set("max_ranking", strconv.Itoa(*maxRanking))
+		}
+		if *titlePrefix != "" {
+			query.Set("title_prefix", *titlePrefix)
+		}
+
+		var err error
+		filter, err = controllers.MovieFilterFromQuery(query)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			return 2
+		}
+	}
+
+	if *backup {
+		delete(filter, "deleted_at")
+	}
+
+	var out io.Writer = os.Stdout
+
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error: could not create output file:", err)
+			return 1
+		}
+		defer file.Close()
+		out = file
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Hour)
+	defer cancel()
+
+	count, err := controllers.ExportCollection(ctx, out, *collection, *format, filter)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error: export failed:", err)
+		return 1
+	}
+
+	fmt.Fprintf(os.Stderr, "Exported %d %s\n", count, *collection)
+	return 0
+}
@@ -0,0 +1,180 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/llms/ollama"
+	"github.com/tmc/langchaingo/llms/openai"
+)
+
+const (
+	ProviderOpenAI           = "openai"
+	ProviderOpenAICompatible = "openai-compatible"
+	ProviderOllama           = "ollama"
+	ProviderRuleBased        = "rule-based"
+	ProviderFake             = "fake"
+)
+
+type Completion struct {
+	Text             string
+	PromptTokens     int
+	CompletionTokens int
+}
+
+// Provider is a text completion backend. Everything that talks to an LLM goes
+// through it, so the backend is chosen by configuration rather than code.
+type Provider interface {
+	Name() string
+	Model() string
+	Complete(ctx context.Context, prompt string) (Completion, error)
+}
+
+type langchainProvider struct {
+	name  string
+	model string
+	llm   llms.Model
+}
+
+func (provider *langchainProvider) Name() string {
+	return provider.name
+}
+
+func (provider *langchainProvider) Model() string {
+	return provider.model
+}
+
+func (provider *langchainProvider) Complete(ctx context.Context, prompt string) (Completion, error) {
+	response, err := provider.llm.GenerateContent(ctx, []llms.MessageContent{
+		llms.TextParts(llms.ChatMessageTypeHuman, prompt),
+	})
+	if err != nil {
+		return Completion{}, err
+	}
+
+	if len(response.Choices) == 0 {
+		return Completion{}, errors.New("empty response from " + provider.name)
+	}
+
+	choice := response.Choices[0]
+
+	return Completion{
+		Text:             choice.Content,
+		PromptTokens:     intFromInfo(choice.GenerationInfo, "PromptTokens"),
+		CompletionTokens: intFromInfo(choice.GenerationInfo, "CompletionTokens"),
+	}, nil
+}
+
+func intFromInfo(info map[string]any, key string) int {
+	switch value := info[key].(type) {
+	case int:
+		return value
+	case int32:
+		return int(value)
+	case int64:
+		return int(value)
+	case float64:
+		return int(value)
+	default:
+		return 0
+	}
+}
+
+// FakeProvider always answers with Response. It is meant for tests and for
+// running the server without any model at all.
+type FakeProvider struct {
+	Response string
+}
+
+func (provider *FakeProvider) Name() string {
+	return ProviderFake
+}
+
+func (provider *FakeProvider) Model() string {
+	return ProviderFake
+}
+
+func (provider *FakeProvider) Complete(ctx context.Context, prompt string) (Completion, error) {
+	if err := ctx.Err(); err != nil {
+		return Completion{}, err
+	}
+
+	return Completion{Text: provider.Response}, nil
+}
+
+func envOrDefault(key string, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+
+	return fallback
+}
+
+// NewProviderFromEnv builds the provider named by LLM_PROVIDER:
+//
+//	openai            OpenAI, needs OPENAI_API_KEY
+//	openai-compatible any OpenAI-style API at LLM_BASE_URL (vLLM, LM Studio, Ollama's /v1)
+//	ollama            a native Ollama server at LLM_BASE_URL
+//	fake              always answers LLM_FAKE_RESPONSE
+//
+// LLM_MODEL overrides the provider's default model.
+func NewProviderFromEnv() (Provider, error) {
+	name := strings.ToLower(envOrDefault("LLM_PROVIDER", ProviderOpenAI))
+
+	switch name {
+	case ProviderOpenAI:
+		token := os.Getenv("OPENAI_API_KEY")
+		if token == "" {
+			return nil, errors.New("OPENAI_API_KEY not set in environment")
+		}
+
+		model := envOrDefault("LLM_MODEL", "gpt-3.5-turbo")
+
+		client, err := openai.New(openai.WithToken(token), openai.WithModel(model))
+		if err != nil {
+			return nil, err
+		}
+
+		return &langchainProvider{name: name, model: model, llm: client}, nil
+
+	case ProviderOpenAICompatible:
+		baseURL := os.Getenv("LLM_BASE_URL")
+		if baseURL == "" {
+			return nil, errors.New("LLM_BASE_URL not set in environment")
+		}
+
+		model := os.Getenv("LLM_MODEL")
+		if model == "" {
+			return nil, errors.New("LLM_MODEL not set in environment")
+		}
+
+		// Local servers usually ignore the key, but the client insists on one.
+		token := envOrDefault("OPENAI_API_KEY", "not-needed")
+
+		client, err := openai.New(openai.WithToken(token), openai.WithBaseURL(baseURL), openai.WithModel(model))
+		if err != nil {
+			return nil, err
+		}
+
+		return &langchainProvider{name: name, model: model, llm: client}, nil
+
+	case ProviderOllama:
+		model := envOrDefault("LLM_MODEL", "llama3")
+
+		client, err := ollama.New(ollama.WithServerURL(envOrDefault("LLM_BASE_URL", "http://localhost:11434")), ollama.WithModel(model))
+		if err != nil {
+			return nil, err
+		}
+
+		return &langchainProvider{name: name, model: model, llm: client}, nil
+
+	case ProviderFake:
+		return &FakeProvider{Response: os.Getenv("LLM_FAKE_RESPONSE")}, nil
+
+	default:
+		return nil, errors.New("unknown LLM_PROVIDER " + name)
+	}
+}
@@ -0,0 +1,148 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+var ErrModerationNotDetermined = errors.New("could not determine moderation labels from response")
+
+// ModerationResult lists the labels a model gave a review; no labels means
+// the review is fine.
+type ModerationResult struct {
+	Labels           []string
+	Reason           string
+	Provider         string
+	Model            string
+	Prompt           string
+	RawResponse      string
+	PromptTokens     int
+	CompletionTokens int
+}
+
+// ReviewModerator classifies a user review of the movie called title.
+type ReviewModerator interface {
+	ModerateReview(ctx context.Context, title string, review string) (ModerationResult, error)
+	ModelInfo() (provider string, model string)
+}
+
+const moderationPrompt = `You moderate user reviews of the movie "%s". Classify the review below with any of these labels that apply: ` +
+	`spam (advertising, links, gibberish), abuse (insults, hate, harassment), spoiler (reveals plot twists or the ending), ` +
+	`off_topic (not about the movie). Answer only with a JSON object of the form {"labels": [...], "reason": "<short reason>"}, ` +
+	`with an empty list when the review is fine. Review: `
+
+// PromptModerator asks an LLM provider to label a review.
+type PromptModerator struct {
+	Provider Provider
+}
+
+func (moderator *PromptModerator) ModelInfo() (string, string) {
+	return moderator.Provider.Name(), moderator.Provider.Model()
+}
+
+func (moderator *PromptModerator) ModerateReview(ctx context.Context, title string, review string) (ModerationResult, error) {
+	prompt := fmt.Sprintf(moderationPrompt, title) + review
+
+	completion, err := moderator.Provider.Complete(ctx, prompt)
+
+	result := ModerationResult{
+		Provider:         moderator.Provider.Name(),
+		Model:            moderator.Provider.Model(),
+		Prompt:           prompt,
+		RawResponse:      completion.Text,
+		PromptTokens:     completion.PromptTokens,
+		CompletionTokens: completion.CompletionTokens,
+	}
+	if err != nil {
+		return result, err
+	}
+
+	labels, reason, ok := ParseModerationResponse(completion.Text)
+	if !ok {
+		return result, ErrModerationNotDetermined
+	}
+
+	result.Labels, result.Reason = labels, reason
+	return result, nil
+}
+
+// ParseModerationResponse reads the labels from a {"labels": [...]} answer,
+// or failing that from label names mentioned in plain text. A plain answer
+// of "none", "ok" or "clean" means no labels.
+func ParseModerationResponse(response string) ([]string, string, bool) {
+	if match := jsonObjectPattern.FindString(response); match != "" {
+		var structured struct {
+			Labels []string `json:"labels"`
+			Reason string   `json:"reason"`
+		}
+		if err := json.Unmarshal([]byte(match), &structured); err == nil && structured.Labels != nil {
+			var labels []string
+			for _, label := range structured.Labels {
+				label = strings.ReplaceAll(strings.ToLower(strings.TrimSpace(label)), "-", "_")
+				if slices.Contains(ModerationLabels, label) && !slices.Contains(labels, label) {
+					labels = append(labels, label)
+				}
+			}
+			return labels, structured.Reason, true
+		}
+	}
+
+	normalized := strings.ReplaceAll(strings.ToLower(response), "-", "_")
+	normalized = strings.ReplaceAll(normalized, "off topic", "off_topic")
+
+	var labels []string
+	for _, label := range ModerationLabels {
+		if strings.Contains(normalized, label) {
+			labels = append(labels, label)
+		}
+	}
+	if len(labels) > 0 {
+		return labels, "", true
+	}
+
+	switch strings.Trim(strings.TrimSpace(normalized), ".!\"'") {
+	case "none", "ok", "clean", "fine", "[]":
+		return nil, "", true
+	}
+
+	return nil, "", false
+}
+
+// NewReviewModeratorFromEnv returns a PromptModerator for the provider named
+// by LLM_PROVIDER, or nil when LLM_PROVIDER is rule-based and only the
+// moderation rules apply. MODERATION_LLM=false turns the model off as well.
+func NewReviewModeratorFromEnv() (ReviewModerator, error) {
+	if strings.ToLower(os.Getenv("LLM_PROVIDER")) == ProviderRuleBased || strings.ToLower(os.Getenv("MODERATION_LLM")) == "false" {
+		return nil, nil
+	}
+
+	provider, err := NewProviderFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	return &timeoutModerator{
+		ReviewModerator: &PromptModerator{Provider: provider},
+		Timeout:         time.Duration(envInt("LLM_TIMEOUT_SECONDS", 30)) * time.Second,
+	}, nil
+}
+
+// timeoutModerator bounds each moderation call; failed calls are retried by
+// the moderation sweeper rather than here.
+type timeoutModerator struct {
+	ReviewModerator
+	Timeout time.Duration
+}
+
+func (moderator *timeoutModerator) ModerateReview(ctx context.Context, title string, review string) (ModerationResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, moderator.Timeout)
+	defer cancel()
+
+	return moderator.ReviewModerator.ModerateReview(ctx, title, review)
+}
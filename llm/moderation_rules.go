@@ -0,0 +1,112 @@
+package llm
+
+import (
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/Neph-dev/MovieStreamServer/utils"
+)
+
+const (
+	ModerationSpam     = "spam"
+	ModerationAbuse    = "abuse"
+	ModerationSpoiler  = "spoiler"
+	ModerationOffTopic = "off_topic"
+)
+
+// ModerationLabels are the categories a review can be flagged for.
+var ModerationLabels = []string{ModerationSpam, ModerationAbuse, ModerationSpoiler, ModerationOffTopic}
+
+type moderationRule struct {
+	Label   string
+	Name    string
+	Pattern *regexp.Regexp
+	// SkipNegated ignores matches right after a negation, so that "no
+	// spoiler alert needed" is not taken for a spoiler alert.
+	SkipNegated bool
+}
+
+// Phone numbers need the grouping of one, either international ("+44 20 7946
+// 0958") or North American ("(555) 123-4567"), so that years, scores and
+// runtimes written next to each other do not look like one.
+var moderationRules = []moderationRule{
+	{ModerationSpam, "link", regexp.MustCompile(`(?i)(https?://|www\.)\S+`), false},
+	{ModerationSpam, "advertising", regexp.MustCompile(`(?i)\b(buy now|click here|free money|promo code|discount code|visit my|subscribe to my|follow me on)\b`), false},
+	{ModerationSpam, "contact details", regexp.MustCompile(`(?i)[a-z0-9._%+-]+@[a-z0-9.-]+\.[a-z]{2,}|\+\d{1,3}([\s.-]?\(?\d{2,4}\)?){3,5}|(\(\d{3}\)\s?|\b\d{3}[\s.-])\d{3}[\s.-]\d{4}\b`), false},
+	{ModerationAbuse, "insult", regexp.MustCompile(`(?i)\b(idiots?|morons?|retards?|scum|kys|kill yourself|go die)\b`), false},
+	{ModerationSpoiler, "spoiler marker", regexp.MustCompile(`(?i)\b(spoilers? (alert|warning|ahead|below)|dies at the end|the twist is that|the killer is|turns out (he|she|they) (is|was|were))\b`), true},
+}
+
+var negation = regexp.MustCompile(`(?i)\b(no|not|without|zero)\s+(major\s+|real\s+)?$`)
+
+// RuleMatch is a moderation rule that matched a review.
+type RuleMatch struct {
+	Label string `json:"label"`
+	Rule  string `json:"rule"`
+}
+
+// CheckModerationRules runs the keyword and pattern rules that are applied
+// before any model sees a review. MODERATION_BLOCKED_WORDS adds a
+// comma-separated list of words flagged as abuse.
+func CheckModerationRules(text string) []RuleMatch {
+	var matches []RuleMatch
+
+	for _, rule := range moderationRules {
+		if rule.matches(text) {
+			matches = append(matches, RuleMatch{Label: rule.Label, Rule: rule.Name})
+		}
+	}
+
+	if hasLongRun(text, 10) {
+		matches = append(matches, RuleMatch{Label: ModerationSpam, Rule: "repeated characters"})
+	}
+
+	words := map[string]bool{}
+	for _, word := range strings.Fields(utils.NormalizeText(text)) {
+		words[word] = true
+	}
+	for _, blocked := range strings.Split(os.Getenv("MODERATION_BLOCKED_WORDS"), ",") {
+		if blocked = strings.ToLower(strings.TrimSpace(blocked)); blocked != "" && words[blocked] {
+			matches = append(matches, RuleMatch{Label: ModerationAbuse, Rule: "blocked word"})
+			break
+		}
+	}
+
+	return matches
+}
+
+func (rule moderationRule) matches(text string) bool {
+	if !rule.SkipNegated {
+		return rule.Pattern.MatchString(text)
+	}
+
+	for _, match := range rule.Pattern.FindAllStringIndex(text, -1) {
+		if !negation.MatchString(text[:match[0]]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hasLongRun reports whether a character repeats at least n times in a row,
+// which Go's regexp cannot express.
+func hasLongRun(text string, n int) bool {
+	var previous rune
+	run := 0
+
+	for _, r := range text {
+		if r == previous {
+			run++
+		} else {
+			previous, run = r, 1
+		}
+
+		if run >= n && r != ' ' {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,51 @@
+package llm
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestCheckModerationRules(t *testing.T) {
+	t.Setenv("MODERATION_BLOCKED_WORDS", "")
+
+	tests := []struct {
+		text  string
+		rules []string
+	}{
+		// Benign reviews.
+		{"No spoilers, but the last act is worth the wait.", nil},
+		{"A spoiler-free review: go see it.", nil},
+		{"Spoilers are everywhere online, avoid them before watching.", nil},
+		{"No spoiler alert needed, the trailer shows everything.", nil},
+		{"Released (1994) 9.5 10 would watch again.", nil},
+		{"Runtime 142 min, rated 8.7/10 by 2,345,678 voters.", nil},
+		{"Saw it on 12.05.2024 at 19.30 with 3 friends.", nil},
+		{"The ending is beautiful and the score is great.", nil},
+		{"I watched it 3 times in 2023 and 4 times in 2024.", nil},
+
+		// Reviews the rules should catch.
+		{"Spoiler alert: the dog survives.", []string{"spoiler marker"}},
+		{"Great film, but the twist is that he was dead all along.", []string{"spoiler marker"}},
+		{"It turns out she was the killer.", []string{"spoiler marker"}},
+		{"Call me on +44 20 7946 0958 for cheap tickets", []string{"contact details"}},
+		{"Text (555) 123-4567 now", []string{"contact details"}},
+		{"reach me at 555-123-4567", []string{"contact details"}},
+		{"mail me at fan@example.com", []string{"contact details"}},
+		{"Watch it free at www.example.com", []string{"link"}},
+		{"Only idiots like this", []string{"insult"}},
+		{"Sooooooooooo good", []string{"repeated characters"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.text, func(t *testing.T) {
+			var rules []string
+			for _, match := range CheckModerationRules(test.text) {
+				rules = append(rules, match.Rule)
+			}
+
+			if !slices.Equal(rules, test.rules) {
+				t.Errorf("CheckModerationRules(%q) matched %q, want %q", test.text, rules, test.rules)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package llm
+
+import (
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+// PromptData is what a prompt template is rendered with.
+type PromptData struct {
+	Rankings string
+	Title    string
+	Genres   string
+	Review   string
+}
+
+var placeholderPattern = regexp.MustCompile(`\{(rankings|title|genres|review)\}`)
+
+// compilePrompt turns the {name} placeholders into text/template fields and
+// parses the result. It also reports whether the template places the review
+// itself; templates that don't, like the original BASE_PROMPT_TEMPLATE, have
+// the review appended.
+func compilePrompt(source string) (*template.Template, bool, error) {
+	translated := placeholderPattern.ReplaceAllStringFunc(source, func(placeholder string) string {
+		name := strings.Trim(placeholder, "{}")
+		return "{{." + strings.ToUpper(name[:1]) + name[1:] + "}}"
+	})
+
+	parsed, err := template.New("prompt").Option("missingkey=error").Parse(translated)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return parsed, strings.Contains(translated, ".Review"), nil
+}
+
+// ValidatePromptTemplate checks that a template parses and renders.
+func ValidatePromptTemplate(source string) error {
+	parsed, _, err := compilePrompt(source)
+	if err != nil {
+		return err
+	}
+
+	return parsed.Execute(&strings.Builder{}, PromptData{})
+}
+
+func renderPrompt(source string, data PromptData, jsonMode bool) (string, error) {
+	parsed, placesReview, err := compilePrompt(source)
+	if err != nil {
+		return "", err
+	}
+
+	var prompt strings.Builder
+	if err := parsed.Execute(&prompt, data); err != nil {
+		return "", err
+	}
+
+	if jsonMode {
+		prompt.WriteString(jsonOutputInstruction)
+	}
+	if !placesReview {
+		if jsonMode {
+			prompt.WriteString(" Review: ")
+		}
+		prompt.WriteString(data.Review)
+	}
+
+	return prompt.String(), nil
+}
@@ -0,0 +1,72 @@
+package llm
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+
+	"github.com/Neph-dev/MovieStreamServer/models"
+	"github.com/Neph-dev/MovieStreamServer/utils"
+)
+
+var jsonObjectPattern = regexp.MustCompile(`(?s)\{.*\}`)
+
+// ParseRankingResponse finds the ranking a model meant in its answer. It
+// accepts, in order of preference: a JSON object with a "ranking" field, the
+// bare ranking name with any casing or punctuation, a ranking name somewhere
+// in a longer answer, and finally a name within a couple of typos.
+func ParseRankingResponse(response string, rankings []models.RankingDefinition) (models.RankingDefinition, bool) {
+	if match := jsonObjectPattern.FindString(response); match != "" {
+		var structured struct {
+			Ranking string `json:"ranking"`
+		}
+		if err := json.Unmarshal([]byte(match), &structured); err == nil && structured.Ranking != "" {
+			response = structured.Ranking
+		}
+	}
+
+	normalized := utils.NormalizeText(response)
+	if normalized == "" {
+		return models.RankingDefinition{}, false
+	}
+
+	for _, ranking := range rankings {
+		if normalized == utils.NormalizeText(ranking.RankingName) {
+			return ranking, true
+		}
+	}
+
+	// A longer answer only counts if exactly one ranking name appears in it,
+	// otherwise "not Good but Okay" would be ambiguous.
+	padded := " " + normalized + " "
+	var contained []models.RankingDefinition
+	for _, ranking := range rankings {
+		if strings.Contains(padded, " "+utils.NormalizeText(ranking.RankingName)+" ") {
+			contained = append(contained, ranking)
+		}
+	}
+	if len(contained) == 1 {
+		return contained[0], true
+	}
+
+	best := -1
+	bestDistance := 0
+	for i, ranking := range rankings {
+		name := utils.NormalizeText(ranking.RankingName)
+		distance := utils.Levenshtein(normalized, name)
+
+		if distance > utils.MaxTypos(name) {
+			continue
+		}
+		if best == -1 || distance < bestDistance {
+			best, bestDistance = i, distance
+		} else if distance == bestDistance {
+			best = -2
+		}
+	}
+	if best >= 0 {
+		return rankings[best], true
+	}
+
+	return models.RankingDefinition{}, false
+}
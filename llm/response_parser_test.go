@@ -0,0 +1,47 @@
+package llm
+
+import (
+	"testing"
+
+	"github.com/Neph-dev/MovieStreamServer/models"
+)
+
+func TestParseRankingResponse(t *testing.T) {
+	rankings := []models.RankingDefinition{
+		{RankingValue: 1, RankingName: "Excellent"},
+		{RankingValue: 2, RankingName: "Good"},
+		{RankingValue: 3, RankingName: "Okay"},
+		{RankingValue: 4, RankingName: "Bad"},
+		{RankingValue: 5, RankingName: "Terrible"},
+		{RankingValue: 6, RankingName: "Bold"},
+	}
+
+	tests := []struct {
+		name     string
+		response string
+		want     int
+		ok       bool
+	}{
+		{"json field", `Sure! {"ranking": "Okay", "reason": "fine"}`, 3, true},
+		{"json field wins over text", `Good, I think. {"ranking": "Bad"}`, 4, true},
+		{"json without ranking falls back to text", `{"verdict": "x"} Terrible`, 5, true},
+		{"exact name", "Excellent", 1, true},
+		{"casing and punctuation", "  excellent!! ", 1, true},
+		{"unique name in a sentence", "I would call this one bad overall.", 4, true},
+		{"two names in a sentence", "Not good but okay.", 0, false},
+		{"name inside a word does not count", "Goodness me", 0, false},
+		{"typo", "Terible", 5, true},
+		{"tie in distance", "Gold", 0, false},
+		{"too many typos", "Exclnt", 0, false},
+		{"empty", "  ...  ", 0, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, ok := ParseRankingResponse(test.response, rankings)
+			if ok != test.ok || (ok && got.RankingValue != test.want) {
+				t.Errorf("ParseRankingResponse(%q) = %d, %v; want %d, %v", test.response, got.RankingValue, ok, test.want, test.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math/rand"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Neph-dev/MovieStreamServer/models"
+)
+
+var transientStatusPattern = regexp.MustCompile(`\b(429|500|502|503|504)\b`)
+
+// IsTransient reports whether err is worth retrying: timeouts, network
+// failures, rate limiting and 5xx answers from the provider.
+func IsTransient(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	message := strings.ToLower(err.Error())
+	if transientStatusPattern.MatchString(message) {
+		return true
+	}
+
+	for _, hint := range []string{"rate limit", "timeout", "temporarily", "connection reset", "connection refused", "unexpected eof"} {
+		if strings.Contains(message, hint) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// RetryingRanker bounds each attempt of the wrapped ranker with Timeout and
+// retries transient failures and unparseable answers with exponential backoff.
+// The result counts every attempt and the tokens they used.
+type RetryingRanker struct {
+	Ranker      ReviewRanker
+	MaxAttempts int
+	Timeout     time.Duration
+	BaseDelay   time.Duration
+}
+
+func (ranker *RetryingRanker) RankReview(ctx context.Context, input ReviewInput, rankings []models.RankingDefinition) (RankingResult, error) {
+	var result RankingResult
+	var err error
+	var attempts, promptTokens, completionTokens int
+
+	for attempt := 1; attempt <= max(ranker.MaxAttempts, 1); attempt++ {
+		attemptCtx, cancel := context.WithTimeout(ctx, ranker.Timeout)
+		result, err = ranker.Ranker.RankReview(attemptCtx, input, rankings)
+		cancel()
+
+		attempts++
+		promptTokens += result.PromptTokens
+		completionTokens += result.CompletionTokens
+		result.Attempts, result.PromptTokens, result.CompletionTokens = attempts, promptTokens, completionTokens
+
+		if err == nil {
+			return result, nil
+		}
+
+		if ctx.Err() != nil {
+			return result, ctx.Err()
+		}
+
+		if !errors.Is(err, ErrRankingNotDetermined) && !IsTransient(err) {
+			return result, err
+		}
+
+		if attempt == ranker.MaxAttempts {
+			break
+		}
+
+		delay := ranker.BaseDelay << (attempt - 1)
+		delay += time.Duration(rand.Int63n(int64(delay)/2 + 1))
+
+		log.Printf("Review ranking attempt %d failed (%v), retrying in %s", attempt, err, delay)
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return result, ctx.Err()
+		}
+	}
+
+	return result, err
+}
+
+func (ranker *RetryingRanker) ModelInfo() (string, string) {
+	return ranker.Ranker.ModelInfo()
+}
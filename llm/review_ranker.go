@@ -0,0 +1,232 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Neph-dev/MovieStreamServer/models"
+	"github.com/Neph-dev/MovieStreamServer/utils"
+)
+
+var ErrRankingNotDetermined = errors.New("could not determine ranking from response")
+
+// RankingResult is the ranking chosen for a review together with where it came
+// from, so callers can store and audit it. Token counts are zero when the
+// provider does not report them.
+type RankingResult struct {
+	Ranking          models.Ranking
+	Provider         string
+	Model            string
+	PromptVersion    int
+	Prompt           string
+	RawResponse      string
+	Attempts         int
+	PromptTokens     int
+	CompletionTokens int
+}
+
+// ReviewInput is a review to rank along with the movie it is about. Prompt
+// overrides the ranker's own template when set.
+type ReviewInput struct {
+	Title  string
+	Genres []string
+	Review string
+	Prompt *models.PromptTemplate
+}
+
+// ReviewRanker picks one of the non-default rankings for a review. ModelInfo
+// names the provider and model it asks, before any review is ranked.
+type ReviewRanker interface {
+	RankReview(ctx context.Context, input ReviewInput, rankings []models.RankingDefinition) (RankingResult, error)
+	ModelInfo() (provider string, model string)
+}
+
+func rankableRankings(rankings []models.RankingDefinition) []models.RankingDefinition {
+	var rankable []models.RankingDefinition
+
+	for _, ranking := range rankings {
+		if !ranking.IsDefault {
+			rankable = append(rankable, ranking)
+		}
+	}
+
+	return rankable
+}
+
+const jsonOutputInstruction = ` Answer only with a JSON object of the form {"ranking": "<one of the words>"}.`
+
+// PromptRanker asks an LLM provider to answer with one ranking name, or with
+// a {"ranking": ...} JSON object when JSONMode is set. Template is used, as
+// prompt version 0, for inputs that carry no prompt of their own.
+type PromptRanker struct {
+	Provider Provider
+	Template string
+	JSONMode bool
+}
+
+func (ranker *PromptRanker) RankReview(ctx context.Context, input ReviewInput, rankings []models.RankingDefinition) (RankingResult, error) {
+	rankable := rankableRankings(rankings)
+
+	names := make([]string, 0, len(rankable))
+	for _, ranking := range rankable {
+		names = append(names, ranking.RankingName)
+	}
+
+	source, version := ranker.Template, 0
+	if input.Prompt != nil {
+		source, version = input.Prompt.Template, input.Prompt.Version
+	}
+
+	prompt, err := renderPrompt(source, PromptData{
+		Rankings: strings.Join(names, ","),
+		Title:    input.Title,
+		Genres:   strings.Join(input.Genres, ", "),
+		Review:   input.Review,
+	}, ranker.JSONMode)
+	if err != nil {
+		return RankingResult{}, err
+	}
+
+	completion, err := ranker.Provider.Complete(ctx, prompt)
+	if err != nil {
+		return RankingResult{}, err
+	}
+
+	result := RankingResult{
+		Provider:         ranker.Provider.Name(),
+		Model:            ranker.Provider.Model(),
+		PromptVersion:    version,
+		Prompt:           prompt,
+		RawResponse:      completion.Text,
+		Attempts:         1,
+		PromptTokens:     completion.PromptTokens,
+		CompletionTokens: completion.CompletionTokens,
+	}
+
+	ranking, ok := ParseRankingResponse(completion.Text, rankable)
+	if !ok {
+		return result, ErrRankingNotDetermined
+	}
+
+	result.Ranking = ranking.Ranking()
+	return result, nil
+}
+
+func (ranker *PromptRanker) ModelInfo() (string, string) {
+	return ranker.Provider.Name(), ranker.Provider.Model()
+}
+
+var positiveWords = map[string]bool{
+	"amazing": true, "awesome": true, "beautiful": true, "best": true, "brilliant": true,
+	"captivating": true, "charming": true, "enjoyable": true, "excellent": true, "fantastic": true,
+	"fun": true, "funny": true, "gripping": true, "good": true, "great": true,
+	"incredible": true, "love": true, "loved": true, "masterpiece": true, "moving": true,
+	"outstanding": true, "perfect": true, "powerful": true, "recommend": true, "stunning": true,
+	"superb": true, "wonderful": true,
+}
+
+var negativeWords = map[string]bool{
+	"awful": true, "bad": true, "bland": true, "boring": true, "confusing": true,
+	"disappointing": true, "dull": true, "forgettable": true, "hate": true, "hated": true,
+	"horrible": true, "mediocre": true, "mess": true, "poor": true, "predictable": true,
+	"slow": true, "terrible": true, "tedious": true, "waste": true, "weak": true,
+	"worst": true,
+}
+
+var negations = map[string]bool{
+	"not": true, "no": true, "never": true, "isn": true, "wasn": true, "don": true, "didn": true,
+}
+
+// RuleBasedRanker is a deterministic keyword-sentiment ranker for tests and
+// deployments without any model. It assumes lower ranking values are better,
+// as GetRecommendedMovies does.
+type RuleBasedRanker struct{}
+
+func (ranker *RuleBasedRanker) RankReview(ctx context.Context, input ReviewInput, rankings []models.RankingDefinition) (RankingResult, error) {
+	if err := ctx.Err(); err != nil {
+		return RankingResult{}, err
+	}
+
+	rankable := rankableRankings(rankings)
+	if len(rankable) == 0 {
+		return RankingResult{}, ErrRankingNotDetermined
+	}
+
+	sort.Slice(rankable, func(i, j int) bool {
+		return rankable[i].RankingValue < rankable[j].RankingValue
+	})
+
+	score := 0
+	words := strings.Fields(utils.NormalizeText(input.Review))
+
+	for i, word := range words {
+		sign := 1
+		if i > 0 && negations[words[i-1]] {
+			sign = -1
+		}
+
+		if positiveWords[word] {
+			score += sign
+		} else if negativeWords[word] {
+			score -= sign
+		}
+	}
+
+	// Squash the score into [-1, 1] and spread it over the scale, best first.
+	sentiment := float64(score) / (math.Abs(float64(score)) + 2)
+	index := int(math.Round((1 - sentiment) / 2 * float64(len(rankable)-1)))
+
+	return RankingResult{
+		Ranking:     rankable[index].Ranking(),
+		Provider:    ProviderRuleBased,
+		Model:       ProviderRuleBased,
+		RawResponse: rankable[index].RankingName,
+		Attempts:    1,
+	}, nil
+}
+
+func (ranker *RuleBasedRanker) ModelInfo() (string, string) {
+	return ProviderRuleBased, ProviderRuleBased
+}
+
+func envInt(key string, fallback int) int {
+	if value, err := strconv.Atoi(os.Getenv(key)); err == nil && value > 0 {
+		return value
+	}
+
+	return fallback
+}
+
+// NewReviewRankerFromEnv returns the ranker selected by LLM_PROVIDER. The
+// rule-based ranker needs no model; every other provider is prompted with the
+// input's prompt template, or BASE_PROMPT_TEMPLATE when it has none
+// (LLM_OUTPUT_MODE=json asks for structured output), and wrapped in a
+// RetryingRanker configured by LLM_TIMEOUT_SECONDS, LLM_MAX_ATTEMPTS and
+// LLM_RETRY_BASE_DELAY_MS.
+func NewReviewRankerFromEnv() (ReviewRanker, error) {
+	if strings.ToLower(os.Getenv("LLM_PROVIDER")) == ProviderRuleBased {
+		return &RuleBasedRanker{}, nil
+	}
+
+	provider, err := NewProviderFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	return &RetryingRanker{
+		Ranker: &PromptRanker{
+			Provider: provider,
+			Template: os.Getenv("BASE_PROMPT_TEMPLATE"),
+			JSONMode: strings.ToLower(os.Getenv("LLM_OUTPUT_MODE")) == "json",
+		},
+		MaxAttempts: envInt("LLM_MAX_ATTEMPTS", 3),
+		Timeout:     time.Duration(envInt("LLM_TIMEOUT_SECONDS", 30)) * time.Second,
+		BaseDelay:   time.Duration(envInt("LLM_RETRY_BASE_DELAY_MS", 500)) * time.Millisecond,
+	}, nil
+}
@@ -0,0 +1,54 @@
+package llm
+
+import (
+	"sync"
+
+	"github.com/pkoukk/tiktoken-go"
+	tiktoken_loader "github.com/pkoukk/tiktoken-go-loader"
+)
+
+var encodings sync.Map
+
+var offlineEncodingsOnce sync.Once
+
+// encodingFor returns the tokenizer of model, falling back to cl100k_base for
+// models tiktoken does not know. The encodings are embedded in the binary;
+// tiktoken would otherwise download them on first use, with no timeout, from
+// inside a ranking or moderation call.
+func encodingFor(model string) *tiktoken.Tiktoken {
+	offlineEncodingsOnce.Do(func() {
+		tiktoken.SetBpeLoader(tiktoken_loader.NewOfflineLoader())
+	})
+
+	if cached, ok := encodings.Load(model); ok {
+		return cached.(*tiktoken.Tiktoken)
+	}
+
+	encoding, err := tiktoken.EncodingForModel(model)
+	if err != nil {
+		encoding, err = tiktoken.GetEncoding("cl100k_base")
+	}
+	if err != nil {
+		// Only reachable with a broken embedded encoding; every count for
+		// this model is then an estimate.
+		encoding = nil
+	}
+
+	encodings.Store(model, encoding)
+	return encoding
+}
+
+// CountTokens counts the tokens of text as the model would, for providers
+// that do not report usage. When no encoding is available it estimates four
+// characters per token.
+func CountTokens(model string, text string) int {
+	if text == "" {
+		return 0
+	}
+
+	if encoding := encodingFor(model); encoding != nil {
+		return len(encoding.Encode(text, nil, nil))
+	}
+
+	return (len(text) + 3) / 4
+}
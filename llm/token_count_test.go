@@ -0,0 +1,23 @@
+package llm
+
+import "testing"
+
+// The encodings are embedded, so counting works without network access.
+func TestCountTokensOffline(t *testing.T) {
+	tests := []struct {
+		model string
+		text  string
+		want  int
+	}{
+		{"gpt-4o", "", 0},
+		{"gpt-4o", "hello world", 2},
+		{"gpt-3.5-turbo", "hello world", 2},
+		{"some-unknown-model", "hello world", 2},
+	}
+
+	for _, test := range tests {
+		if got := CountTokens(test.model, test.text); got != test.want {
+			t.Errorf("CountTokens(%q, %q) = %d, want %d", test.model, test.text, got, test.want)
+		}
+	}
+}
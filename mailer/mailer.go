@@ -0,0 +1,72 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	ProviderSMTP   = "smtp"
+	ProviderOutbox = "outbox"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers transactional email. Everything that sends mail goes
+// through it, so delivery is chosen by configuration rather than code.
+type Mailer interface {
+	Send(ctx context.Context, message Message) error
+}
+
+func envOrDefault(key string, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+
+	return fallback
+}
+
+// NewMailerFromEnv selects the mailer named by MAIL_PROVIDER. The outbox is
+// the default, so a development setup never sends real email by accident.
+func NewMailerFromEnv() (Mailer, error) {
+	name := strings.ToLower(envOrDefault("MAIL_PROVIDER", ProviderOutbox))
+
+	switch name {
+	case ProviderSMTP:
+		host := os.Getenv("SMTP_HOST")
+		if host == "" {
+			return nil, errors.New("SMTP_HOST not set in environment")
+		}
+
+		port, err := strconv.Atoi(envOrDefault("SMTP_PORT", "587"))
+		if err != nil {
+			return nil, errors.New("SMTP_PORT must be a number")
+		}
+
+		from := os.Getenv("MAIL_FROM")
+		if from == "" {
+			return nil, errors.New("MAIL_FROM not set in environment")
+		}
+
+		return &SMTPMailer{
+			Host:     host,
+			Port:     port,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     from,
+		}, nil
+
+	case ProviderOutbox:
+		return &OutboxMailer{Dir: os.Getenv("MAIL_OUTBOX_DIR"), From: os.Getenv("MAIL_FROM")}, nil
+	}
+
+	return nil, fmt.Errorf("unknown MAIL_PROVIDER %q", name)
+}
@@ -0,0 +1,41 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// OutboxMailer keeps mail local for development and testing. With a Dir each
+// message is written there as an .eml file. Without one only the recipient
+// and subject are logged: bodies carry reset and verification tokens, which
+// must not end up in logs.
+type OutboxMailer struct {
+	Dir  string
+	From string
+}
+
+func (mailer *OutboxMailer) Send(_ context.Context, message Message) error {
+	if mailer.Dir == "" {
+		log.Printf("Outbox mail to %s (%q) discarded, set MAIL_OUTBOX_DIR to keep its contents", message.To, message.Subject)
+		return nil
+	}
+
+	from := mailer.From
+	if from == "" {
+		from = "no-reply@localhost"
+	}
+
+	if err := os.MkdirAll(mailer.Dir, 0o700); err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%s-%s.eml", time.Now().UTC().Format("20060102T150405"), bson.NewObjectID().Hex())
+
+	return os.WriteFile(filepath.Join(mailer.Dir, name), formatMessage(from, message), 0o600)
+}
@@ -0,0 +1,60 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SMTPMailer sends through an SMTP server, using STARTTLS when the server
+// offers it. Authentication is skipped when no username is set.
+type SMTPMailer struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+func (mailer *SMTPMailer) Send(ctx context.Context, message Message) error {
+	address := net.JoinHostPort(mailer.Host, strconv.Itoa(mailer.Port))
+
+	var auth smtp.Auth
+	if mailer.Username != "" {
+		auth = smtp.PlainAuth("", mailer.Username, mailer.Password, mailer.Host)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(address, auth, mailer.From, []string{message.To}, formatMessage(mailer.From, message))
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// formatMessage renders a plain-text message. Header values come from our own
+// code, but line breaks are still stripped so they cannot inject headers.
+func formatMessage(from string, message Message) []byte {
+	header := strings.NewReplacer("\r", "", "\n", "")
+
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "From: %s\r\n", header.Replace(from))
+	fmt.Fprintf(&builder, "To: %s\r\n", header.Replace(message.To))
+	fmt.Fprintf(&builder, "Subject: %s\r\n", header.Replace(message.Subject))
+	fmt.Fprintf(&builder, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	builder.WriteString("MIME-Version: 1.0\r\n")
+	builder.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	builder.WriteString("\r\n")
+	builder.WriteString(strings.ReplaceAll(message.Body, "\n", "\r\n"))
+
+	return []byte(builder.String())
+}
@@ -2,16 +2,32 @@ package main
 
 import (
 	"fmt"
+	"os"
 
+	"github.com/Neph-dev/MovieStreamServer/controllers"
 	"github.com/Neph-dev/MovieStreamServer/routes"
 	"github.com/gin-gonic/gin"
 )
 
 func main(){
+	if len(os.Args) > 1 && os.Args[1] == "export" {
+		os.Exit(runExportCommand(os.Args[2:]))
+	}
+
 	router := gin.Default()
 
 	routes.UnprotectedRoutes(router)
 	routes.ProtectedRoutes(router)
+
+	controllers.StartTrashSweeper()
+	controllers.RecoverImportJobs()
+	controllers.MigrateGenres()
+	controllers.MigrateEmailVerification()
+	controllers.MigrateRankingDefaults()
+	controllers.MigratePromptTemplates()
+	controllers.StartReviewWorkers()
+	controllers.RecoverRerankJobs()
+	controllers.StartModerationSweeper()
 	
 	if err := router.Run(":8080"); err != nil {
 		fmt.Println("Failed to start server:", err)
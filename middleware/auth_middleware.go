@@ -31,11 +31,28 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		revoked, err := utils.IsTokenRevoked(claims)
+
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking token revocation"})
+			_context.Abort()
+			return
+		}
+
+		if revoked {
+			_context.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized: token has been revoked", "code": "TOKEN_REVOKED"})
+			_context.Abort()
+			return
+		}
+
 		_context.Set("email", claims.Email)
 		_context.Set("first_name", claims.FirstName)
 		_context.Set("last_name", claims.LastName)
 		_context.Set("userId", claims.UID)
 		_context.Set("role", claims.Role)
+		_context.Set("tokenId", claims.ID)
+		_context.Set("tokenFamily", claims.Family)
+		_context.Set("emailVerified", claims.EmailVerified)
 
 		_context.Next()
 	}
@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/Neph-dev/MovieStreamServer/models"
+	"github.com/Neph-dev/MovieStreamServer/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// RequirePermission must run after AuthMiddleware, which puts the role in the context.
+func RequirePermission(permissions ...string) gin.HandlerFunc {
+	return func(_context *gin.Context) {
+		role, err := utils.GetDataFromContext(_context, "role")
+
+		if err != nil {
+			_context.JSON(http.StatusForbidden, gin.H{"error": "Forbidden: no role found"})
+			_context.Abort()
+			return
+		}
+
+		for _, permission := range permissions {
+			if !models.RoleHasPermission(role, permission) {
+				_context.JSON(http.StatusForbidden, gin.H{"error": "Forbidden: missing permission " + permission})
+				_context.Abort()
+				return
+			}
+		}
+
+		_context.Next()
+	}
+}
@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/Neph-dev/MovieStreamServer/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// RequireVerifiedEmail must run after AuthMiddleware. With
+// UNVERIFIED_USER_ACCESS=full it lets everyone through; otherwise unverified
+// users only reach the exempt routes, matched by their route path.
+func RequireVerifiedEmail(exemptPaths ...string) gin.HandlerFunc {
+	return func(_context *gin.Context) {
+		if _context.GetBool("emailVerified") || utils.UnverifiedUserAccess() == utils.UnverifiedAccessFull || slices.Contains(exemptPaths, _context.FullPath()) {
+			_context.Next()
+			return
+		}
+
+		// The token may predate verification, so check the account itself.
+		userId, err := utils.GetDataFromContext(_context, "userId")
+		if err != nil {
+			_context.JSON(http.StatusForbidden, gin.H{"error": "Forbidden: no user found"})
+			_context.Abort()
+			return
+		}
+
+		verified, err := utils.IsEmailVerified(userId)
+		if err != nil {
+			_context.JSON(http.StatusInternalServerError, gin.H{"error": "Error checking email verification"})
+			_context.Abort()
+			return
+		}
+
+		if !verified {
+			_context.JSON(http.StatusForbidden, gin.H{"error": "Forbidden: email address is not verified", "code": "EMAIL_NOT_VERIFIED"})
+			_context.Abort()
+			return
+		}
+
+		_context.Next()
+	}
+}
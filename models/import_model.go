@@ -0,0 +1,59 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+const (
+	JobStatusQueued    = "queued"
+	JobStatusRunning   = "running"
+	JobStatusCompleted = "completed"
+	JobStatusFailed    = "failed"
+)
+
+const (
+	ImportModeInsert = "insert"
+	ImportModeUpsert = "upsert"
+)
+
+const (
+	ImportRowCreated = "created"
+	ImportRowUpdated = "updated"
+	ImportRowSkipped = "skipped"
+	ImportRowInvalid = "invalid"
+)
+
+type ImportCounts struct {
+	Total   int `bson:"total" json:"total"`
+	Created int `bson:"created" json:"created"`
+	Updated int `bson:"updated" json:"updated"`
+	Skipped int `bson:"skipped" json:"skipped"`
+	Invalid int `bson:"invalid" json:"invalid"`
+}
+
+type ImportJob struct {
+	ID         bson.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
+	JobID      string        `bson:"job_id" json:"job_id"`
+	Status     string        `bson:"status" json:"status"`
+	Mode       string        `bson:"mode" json:"mode"`
+	DryRun     bool          `bson:"dry_run" json:"dry_run"`
+	Format     string        `bson:"format" json:"format"`
+	FileName   string        `bson:"file_name" json:"file_name"`
+	CreatedBy  string        `bson:"created_by" json:"created_by"`
+	Counts     ImportCounts  `bson:"counts" json:"counts"`
+	Error      string        `bson:"error,omitempty" json:"error,omitempty"`
+	CreatedAt  time.Time     `bson:"created_at" json:"created_at"`
+	StartedAt  *time.Time    `bson:"started_at,omitempty" json:"started_at,omitempty"`
+	FinishedAt *time.Time    `bson:"finished_at,omitempty" json:"finished_at,omitempty"`
+}
+
+type ImportRowResult struct {
+	ID     bson.ObjectID `bson:"_id,omitempty" json:"-"`
+	JobID  string        `bson:"job_id" json:"job_id"`
+	Row    int           `bson:"row" json:"row"`
+	ImdbID string        `bson:"imdb_id,omitempty" json:"imdb_id,omitempty"`
+	Status string        `bson:"status" json:"status"`
+	Reason string        `bson:"reason,omitempty" json:"reason,omitempty"`
+}
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// LLMCacheEntry is a cached ranking, keyed on the prompt version, model and
+// a hash of the review text.
+type LLMCacheEntry struct {
+	ID            bson.ObjectID `bson:"_id,omitempty" json:"-"`
+	Key           string        `bson:"key" json:"key"`
+	PromptVersion int           `bson:"prompt_version" json:"prompt_version"`
+	Provider      string        `bson:"provider" json:"provider"`
+	Model         string        `bson:"model" json:"model"`
+	ReviewHash    string        `bson:"review_hash" json:"review_hash"`
+	Ranking       Ranking       `bson:"ranking" json:"ranking"`
+	RawResponse   string        `bson:"raw_response" json:"raw_response"`
+	CreatedAt     time.Time     `bson:"created_at" json:"created_at"`
+	ExpiresAt     time.Time     `bson:"expires_at" json:"expires_at"`
+}
+
+const (
+	LLMPurposeRanking    = "ranking"
+	LLMPurposeModeration = "moderation"
+)
+
+// LLMUsage records one model request, or a ranking answered by the cache.
+type LLMUsage struct {
+	ID               bson.ObjectID `bson:"_id,omitempty" json:"-"`
+	Purpose          string        `bson:"purpose" json:"purpose"`
+	Provider         string        `bson:"provider" json:"provider"`
+	Model            string        `bson:"model" json:"model"`
+	PromptVersion    int           `bson:"prompt_version" json:"prompt_version"`
+	UserID           string        `bson:"user_id" json:"user_id"`
+	ImdbID           string        `bson:"imdb_id" json:"imdb_id"`
+	Cached           bool          `bson:"cached" json:"cached"`
+	Attempts         int           `bson:"attempts" json:"attempts"`
+	PromptTokens     int           `bson:"prompt_tokens" json:"prompt_tokens"`
+	CompletionTokens int           `bson:"completion_tokens" json:"completion_tokens"`
+	LatencyMs        int64         `bson:"latency_ms" json:"latency_ms"`
+	CostUSD          float64       `bson:"cost_usd" json:"cost_usd"`
+	Error            string        `bson:"error,omitempty" json:"error,omitempty"`
+	CreatedAt        time.Time     `bson:"created_at" json:"created_at"`
+}
@@ -1,6 +1,8 @@
 package models
 
 import (
+	"time"
+
 	"go.mongodb.org/mongo-driver/v2/bson"
 )
 
@@ -14,13 +16,43 @@ type Ranking struct {
 	RankingName  string `bson:"ranking_name" json:"ranking_name" validate:"required"`
 }
 
+const (
+	RankingStatusRanked  = "ranked"
+	RankingStatusPending = "pending"
+	RankingStatusFailed  = "failed"
+)
+
+// RankingDefinition is a document of the rankings collection, the scale the
+// LLM ranks reviews against. Movies embed only the Ranking part of it.
+// The single IsDefault ranking marks movies that have not been ranked yet and
+// is never offered to the LLM.
+type RankingDefinition struct {
+	ID           bson.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
+	RankingValue int           `bson:"ranking_value" json:"ranking_value" validate:"required"`
+	RankingName  string        `bson:"ranking_name" json:"ranking_name" validate:"required,min=2,max=100"`
+	IsDefault    bool          `bson:"is_default" json:"is_default"`
+	Order        int           `bson:"order" json:"order"`
+	CreatedAt    time.Time     `bson:"created_at" json:"created_at"`
+	UpdatedAt    time.Time     `bson:"updated_at" json:"updated_at"`
+}
+
+func (definition RankingDefinition) Ranking() Ranking {
+	return Ranking{RankingValue: definition.RankingValue, RankingName: definition.RankingName}
+}
+
 type Movie struct {
-	ID          bson.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
-	ImdbID      string        `bson:"imdb_id" json:"imdb_id" validate:"required" unique:"true"`
-	Title       string        `bson:"title" json:"title" validate:"required,min=2,max=500"`
-	PosterPath  string        `bson:"poster_path" json:"poster_path" validate:"required,url"`
-	YoutubeID   string        `bson:"youtube_id" json:"youtube_id" validate:"required" unique:"true"`
-	Genre       []Genre       `bson:"genre" json:"genre" validate:"required,dive"`
-	AdminReview string        `bson:"admin_review" json:"admin_review"`
-	Ranking     Ranking       `bson:"ranking" json:"ranking" validate:"required"`
+	ID            bson.ObjectID    `bson:"_id,omitempty" json:"_id,omitempty"`
+	ImdbID        string           `bson:"imdb_id" json:"imdb_id" validate:"required" unique:"true"`
+	Title         string           `bson:"title" json:"title" validate:"required,min=2,max=500"`
+	PosterPath    string           `bson:"poster_path" json:"poster_path" validate:"required,url"`
+	YoutubeID     string           `bson:"youtube_id" json:"youtube_id" validate:"required" unique:"true"`
+	Genre         []Genre          `bson:"genre" json:"genre" validate:"required,dive"`
+	AdminReview   string           `bson:"admin_review" json:"admin_review"`
+	Ranking       Ranking          `bson:"ranking" json:"ranking" validate:"required"`
+	RankingStatus string           `bson:"ranking_status,omitempty" json:"ranking_status,omitempty"`
+	RankingSource *RankingSource   `bson:"ranking_source,omitempty" json:"ranking_source,omitempty"`
+	UserRating    *RatingAggregate `bson:"user_rating,omitempty" json:"user_rating,omitempty"`
+	Version       int64            `bson:"version" json:"version"`
+	DeletedAt     *time.Time       `bson:"deleted_at,omitempty" json:"deleted_at,omitempty"`
+	DeletedBy     string           `bson:"deleted_by,omitempty" json:"deleted_by,omitempty"`
 }
\ No newline at end of file
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// PasswordReset is an issued reset token. Only its hash is stored, so the
+// collection alone cannot be used to reset anyone's password.
+type PasswordReset struct {
+	ID          bson.ObjectID `bson:"_id,omitempty" json:"-"`
+	TokenHash   string        `bson:"token_hash" json:"-"`
+	UserID      string        `bson:"user_id" json:"-"`
+	RequestedIP string        `bson:"requested_ip" json:"-"`
+	CreatedAt   time.Time     `bson:"created_at" json:"-"`
+	ExpiresAt   time.Time     `bson:"expires_at" json:"-"`
+	UsedAt      *time.Time    `bson:"used_at,omitempty" json:"-"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Email       string `json:"email" validate:"required,email"`
+	Token       string `json:"token" validate:"required"`
+	NewPassword string `json:"new_password" validate:"required,min=6"`
+}
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+const (
+	PlaybackStart     = "start"
+	PlaybackHeartbeat = "heartbeat"
+	PlaybackFinish    = "finish"
+)
+
+// PlaybackEvent is a report from a player. EventID is chosen by the client
+// so that a resent event is recognised and applied only once.
+type PlaybackEvent struct {
+	ID              bson.ObjectID `bson:"_id,omitempty" json:"-"`
+	EventID         string        `bson:"event_id" json:"event_id" validate:"required,max=100"`
+	UserID          string        `bson:"user_id" json:"-"`
+	ImdbID          string        `bson:"imdb_id" json:"imdb_id" validate:"required"`
+	SessionID       string        `bson:"session_id,omitempty" json:"session_id,omitempty" validate:"max=100"`
+	Type            string        `bson:"type" json:"type" validate:"required,oneof=start heartbeat finish"`
+	PositionSeconds float64       `bson:"position_seconds" json:"position_seconds" validate:"min=0"`
+	DurationSeconds float64       `bson:"duration_seconds,omitempty" json:"duration_seconds,omitempty" validate:"min=0"`
+	OccurredAt      time.Time     `bson:"occurred_at" json:"occurred_at"`
+	ReceivedAt      time.Time     `bson:"received_at" json:"-"`
+	ExpiresAt       time.Time     `bson:"expires_at" json:"-"`
+}
+
+// WatchProgress is what the playback events of a user and movie add up to.
+// PositionAt is when the stored position was reported, so that events that
+// arrive late never move it backwards.
+type WatchProgress struct {
+	ID              bson.ObjectID `bson:"_id,omitempty" json:"-"`
+	UserID          string        `bson:"user_id" json:"-"`
+	ImdbID          string        `bson:"imdb_id" json:"imdb_id"`
+	PositionSeconds float64       `bson:"position_seconds" json:"position_seconds"`
+	PositionAt      time.Time     `bson:"position_at" json:"-"`
+	DurationSeconds float64       `bson:"duration_seconds" json:"duration_seconds"`
+	StartedAt       time.Time     `bson:"started_at" json:"started_at"`
+	LastWatchedAt   time.Time     `bson:"last_watched_at" json:"last_watched_at"`
+	Watched         bool          `bson:"watched" json:"watched"`
+	FinishedAt      *time.Time    `bson:"finished_at,omitempty" json:"finished_at,omitempty"`
+	Movie           *Movie        `bson:"-" json:"movie,omitempty"`
+}
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// PromptTemplate is a version of the prompt used to rank reviews. Template
+// may use the {rankings}, {title}, {genres} and {review} placeholders, or the
+// equivalent text/template fields {{.Rankings}}, {{.Title}}, {{.Genres}} and
+// {{.Review}}.
+type PromptTemplate struct {
+	ID          bson.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
+	Version     int           `bson:"version" json:"version"`
+	Description string        `bson:"description,omitempty" json:"description,omitempty" validate:"max=500"`
+	Template    string        `bson:"template" json:"template" validate:"required,max=10000"`
+	IsActive    bool          `bson:"is_active" json:"is_active"`
+	CreatedBy   string        `bson:"created_by" json:"created_by"`
+	CreatedAt   time.Time     `bson:"created_at" json:"created_at"`
+	ActivatedAt *time.Time    `bson:"activated_at,omitempty" json:"activated_at,omitempty"`
+}
+
+// RankingSource records what produced a movie's ranking, so it can be
+// audited and reproduced.
+type RankingSource struct {
+	Provider      string    `bson:"provider" json:"provider"`
+	Model         string    `bson:"model" json:"model"`
+	PromptVersion int       `bson:"prompt_version" json:"prompt_version"`
+	RankedAt      time.Time `bson:"ranked_at" json:"ranked_at"`
+}
@@ -0,0 +1,51 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+type RerankCounts struct {
+	Total     int64 `bson:"total" json:"total"`
+	Processed int64 `bson:"processed" json:"processed"`
+	Changed   int64 `bson:"changed" json:"changed"`
+	Unchanged int64 `bson:"unchanged" json:"unchanged"`
+	Failed    int64 `bson:"failed" json:"failed"`
+}
+
+// RerankJob re-ranks every reviewed movie. Checkpoint is the _id of the last
+// movie of the last fully processed batch, the point a resumed job starts
+// from. Active is set while the job is queued or running; a unique index on
+// it keeps a single job active at a time.
+type RerankJob struct {
+	ID            bson.ObjectID  `bson:"_id,omitempty" json:"_id,omitempty"`
+	JobID         string         `bson:"job_id" json:"job_id"`
+	Status        string         `bson:"status" json:"status"`
+	DryRun        bool           `bson:"dry_run" json:"dry_run"`
+	Concurrency   int            `bson:"concurrency" json:"concurrency"`
+	RatePerMinute int            `bson:"rate_per_minute" json:"rate_per_minute"`
+	Checkpoint    *bson.ObjectID `bson:"checkpoint,omitempty" json:"checkpoint,omitempty"`
+	Counts        RerankCounts   `bson:"counts" json:"counts"`
+	CreatedBy     string         `bson:"created_by" json:"created_by"`
+	Error         string         `bson:"error,omitempty" json:"error,omitempty"`
+	Active        bool           `bson:"active,omitempty" json:"-"`
+	LockedBy      string         `bson:"locked_by,omitempty" json:"locked_by,omitempty"`
+	LockedUntil   *time.Time     `bson:"locked_until,omitempty" json:"locked_until,omitempty"`
+	CreatedAt     time.Time      `bson:"created_at" json:"created_at"`
+	StartedAt     *time.Time     `bson:"started_at,omitempty" json:"started_at,omitempty"`
+	FinishedAt    *time.Time     `bson:"finished_at,omitempty" json:"finished_at,omitempty"`
+}
+
+// RerankChange is a line of a re-rank job's diff report.
+type RerankChange struct {
+	ID         bson.ObjectID `bson:"_id,omitempty" json:"-"`
+	JobID      string        `bson:"job_id" json:"job_id"`
+	ImdbID     string        `bson:"imdb_id" json:"imdb_id"`
+	Title      string        `bson:"title" json:"title"`
+	OldRanking Ranking       `bson:"old_ranking" json:"old_ranking"`
+	NewRanking *Ranking      `bson:"new_ranking,omitempty" json:"new_ranking,omitempty"`
+	Changed    bool          `bson:"changed" json:"changed"`
+	Applied    bool          `bson:"applied" json:"applied"`
+	Error      string        `bson:"error,omitempty" json:"error,omitempty"`
+}
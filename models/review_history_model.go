@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+const (
+	ReviewActionUpdate = "update"
+	ReviewActionRevert = "revert"
+	ReviewActionImport = "import"
+)
+
+// ReviewHistoryEntry records one change of a movie's admin review. Ranking,
+// RankingStatus and RankingSource start out as saved with the review and are
+// filled in by the review job once the new review has been ranked.
+type ReviewHistoryEntry struct {
+	ID              bson.ObjectID  `bson:"_id,omitempty" json:"_id,omitempty"`
+	ImdbID          string         `bson:"imdb_id" json:"imdb_id"`
+	Action          string         `bson:"action" json:"action"`
+	AuthorID        string         `bson:"author_id" json:"author_id"`
+	AuthorEmail     string         `bson:"author_email" json:"author_email"`
+	PreviousReview  string         `bson:"previous_review" json:"previous_review"`
+	NewReview       string         `bson:"new_review" json:"new_review"`
+	PreviousRanking Ranking        `bson:"previous_ranking" json:"previous_ranking"`
+	Ranking         Ranking        `bson:"ranking" json:"ranking"`
+	RankingStatus   string         `bson:"ranking_status" json:"ranking_status"`
+	RankingSource   *RankingSource `bson:"ranking_source,omitempty" json:"ranking_source,omitempty"`
+	JobID           string         `bson:"job_id,omitempty" json:"job_id,omitempty"`
+	RevertedFrom    *bson.ObjectID `bson:"reverted_from,omitempty" json:"reverted_from,omitempty"`
+	CreatedAt       time.Time      `bson:"created_at" json:"created_at"`
+}
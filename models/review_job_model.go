@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// Review jobs use the shared job statuses plus JobStatusDead for jobs that
+// ran out of attempts and were dead-lettered.
+const JobStatusDead = "dead"
+
+type ReviewJobResult struct {
+	Ranking       Ranking `bson:"ranking" json:"ranking"`
+	Provider      string  `bson:"provider" json:"provider"`
+	Model         string  `bson:"model" json:"model"`
+	PromptVersion int     `bson:"prompt_version" json:"prompt_version"`
+}
+
+type ReviewJob struct {
+	ID          bson.ObjectID    `bson:"_id,omitempty" json:"_id,omitempty"`
+	JobID       string           `bson:"job_id" json:"job_id"`
+	ImdbID      string           `bson:"imdb_id" json:"imdb_id"`
+	Review      string           `bson:"review" json:"review"`
+	Status      string           `bson:"status" json:"status"`
+	Attempts    int              `bson:"attempts" json:"attempts"`
+	MaxAttempts int              `bson:"max_attempts" json:"max_attempts"`
+	NextRunAt   time.Time        `bson:"next_run_at" json:"next_run_at"`
+	LockedBy    string           `bson:"locked_by,omitempty" json:"locked_by,omitempty"`
+	LockedUntil *time.Time       `bson:"locked_until,omitempty" json:"locked_until,omitempty"`
+	LastError   string           `bson:"last_error,omitempty" json:"last_error,omitempty"`
+	Result      *ReviewJobResult `bson:"result,omitempty" json:"result,omitempty"`
+	CreatedBy   string           `bson:"created_by" json:"created_by"`
+	CreatedAt   time.Time        `bson:"created_at" json:"created_at"`
+	UpdatedAt   time.Time        `bson:"updated_at" json:"updated_at"`
+	FinishedAt  *time.Time       `bson:"finished_at,omitempty" json:"finished_at,omitempty"`
+}
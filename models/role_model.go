@@ -0,0 +1,49 @@
+package models
+
+const (
+	RoleAdmin     = "ADMIN"
+	RoleEditor    = "EDITOR"
+	RoleModerator = "MODERATOR"
+	RoleUser      = "USER"
+)
+
+const (
+	PermissionMovieWrite     = "movie:write"
+	PermissionGenreWrite     = "genre:write"
+	PermissionRankingWrite   = "ranking:write"
+	PermissionReviewWrite    = "review:write"
+	PermissionReviewModerate = "review:moderate"
+	PermissionUserAdmin      = "user:admin"
+)
+
+// RolePermissions is the single source of truth for what each role may do.
+// To add a role, add it here and to the oneof lists on User.Role and RoleUpdate.Role.
+var RolePermissions = map[string][]string{
+	RoleAdmin: {
+		PermissionMovieWrite,
+		PermissionGenreWrite,
+		PermissionRankingWrite,
+		PermissionReviewWrite,
+		PermissionReviewModerate,
+		PermissionUserAdmin,
+	},
+	RoleEditor: {
+		PermissionMovieWrite,
+		PermissionGenreWrite,
+		PermissionReviewWrite,
+	},
+	RoleModerator: {
+		PermissionReviewModerate,
+	},
+	RoleUser: {},
+}
+
+func RoleHasPermission(role string, permission string) bool {
+	for _, granted := range RolePermissions[role] {
+		if granted == permission {
+			return true
+		}
+	}
+
+	return false
+}
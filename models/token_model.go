@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+const (
+	RevocationKindToken = "token"
+	RevocationKindUser  = "user"
+)
+
+// RevokedToken is either a single revoked access token (Kind "token", keyed by
+// its jti) or a per-user cutoff (Kind "user") that rejects every token issued
+// before RevokedBefore. ExpiresAt drives the TTL index on the collection.
+type RevokedToken struct {
+	ID            bson.ObjectID `bson:"_id,omitempty" json:"_id,omitempty"`
+	Kind          string        `bson:"kind" json:"kind"`
+	TokenID       string        `bson:"token_id,omitempty" json:"token_id,omitempty"`
+	UserID        string        `bson:"user_id" json:"user_id"`
+	RevokedBefore time.Time     `bson:"revoked_before,omitempty" json:"revoked_before,omitempty"`
+	// AllowedTokenIDs are tokens issued alongside the revocation, such as
+	// the new pair of a password change, which the cutoff must not reject.
+	AllowedTokenIDs []string  `bson:"allowed_token_ids,omitempty" json:"allowed_token_ids,omitempty"`
+	ExpiresAt       time.Time `bson:"expires_at" json:"expires_at"`
+	CreatedAt       time.Time `bson:"created_at" json:"created_at"`
+}
@@ -13,12 +13,20 @@ type User struct {
 	LastName  	 	string        	`bson:"last_name" json:"last_name" validate:"required,min=2,max=100"`
 	Email     	 	string        	`bson:"email" json:"email" validate:"required,email" unique:"true"`
 	Password  	 	string        	`bson:"password" json:"password" validate:"required,min=6"`
-	Role      	 	string        	`bson:"role" json:"role" validate:"required,oneof=ADMIN USER"`
+	Role      	 	string        	`bson:"role" json:"role" validate:"required,oneof=ADMIN EDITOR MODERATOR USER"`
 	FavouriteGenres []Genre       	`bson:"favourite_genres" json:"favourite_genres" validate:"dive,required"`
 	Token     	 	string        	`bson:"token" json:"token"`
 	RefreshToken 	string        	`bson:"refresh_token" json:"refresh_token"`
+	TokenFamily 	string        	`bson:"token_family" json:"-"`
 	CreatedAt 	 	time.Time       `bson:"created_at" json:"created_at"`
 	UpdatedAt 	 	time.Time       `bson:"updated_at" json:"updated_at"`
+	DeletedAt 	 	*time.Time      `bson:"deleted_at,omitempty" json:"deleted_at,omitempty"`
+	DeletedBy 	 	string          `bson:"deleted_by,omitempty" json:"deleted_by,omitempty"`
+	EmailVerified 	bool            `bson:"email_verified" json:"-"`
+	EmailVerifiedAt *time.Time      `bson:"email_verified_at,omitempty" json:"-"`
+	PendingEmail 	string          `bson:"pending_email,omitempty" json:"-"`
+	EmailChangeTokenHash string     `bson:"email_change_token_hash,omitempty" json:"-"`
+	EmailChangeExpiresAt *time.Time `bson:"email_change_expires_at,omitempty" json:"-"`
 }
 
 type UserLogin struct {
@@ -26,12 +34,52 @@ type UserLogin struct {
 	Password string `json:"password" validate:"required,min=6"`
 }
 
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
 type UserResponse struct {
 	UserID    string 		`json:"user_id"`
 	FirstName string 		`json:"first_name"`
 	LastName  string 		`json:"last_name"`
 	Email     string 		`json:"email"`
+	EmailVerified bool 		`json:"email_verified"`
+	PendingEmail string 	`json:"pending_email,omitempty"`
 	Role      string 		`json:"role"`
 	FavouriteGenres []Genre `json:"favourite_genres"`
-	Token     string 		`json:"token"`
+	CreatedAt time.Time 	`json:"created_at"`
+	UpdatedAt time.Time 	`json:"updated_at"`
+	DeletedAt *time.Time 	`json:"deleted_at,omitempty"`
+}
+
+type EmailVerificationRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+type ResendVerificationRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type RoleUpdate struct {
+	Role string `json:"role" validate:"required,oneof=ADMIN EDITOR MODERATOR USER"`
+}
+
+type ProfileUpdate struct {
+	FirstName       *string  `json:"first_name" validate:"omitempty,min=2,max=100"`
+	LastName        *string  `json:"last_name" validate:"omitempty,min=2,max=100"`
+	FavouriteGenres *[]Genre `json:"favourite_genres" validate:"omitempty,dive,required"`
+}
+
+type EmailChangeRequest struct {
+	NewEmail string `json:"new_email" validate:"required,email"`
+	Password string `json:"password" validate:"required"`
+}
+
+type EmailChangeConfirmation struct {
+	Token string `json:"token" validate:"required"`
+}
+
+type PasswordChangeRequest struct {
+	OldPassword string `json:"old_password" validate:"required"`
+	NewPassword string `json:"new_password" validate:"required,min=6"`
 }
\ No newline at end of file
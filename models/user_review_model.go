@@ -0,0 +1,71 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+const (
+	ModerationPending  = "pending"
+	ModerationApproved = "approved"
+	ModerationFlagged  = "flagged"
+	ModerationRejected = "rejected"
+)
+
+const (
+	ModeratedByAuto      = "auto"
+	ModeratedByRules     = "rules"
+	ModeratedByLLM       = "llm"
+	ModeratedByModerator = "moderator"
+)
+
+// ReviewModeration is where a user review stands in moderation. Reviews are
+// public, and count towards the movie's rating, only once approved.
+type ReviewModeration struct {
+	Status      string     `bson:"status" json:"status"`
+	Labels      []string   `bson:"labels,omitempty" json:"labels,omitempty"`
+	Reason      string     `bson:"reason,omitempty" json:"reason,omitempty"`
+	Source      string     `bson:"source,omitempty" json:"source,omitempty"`
+	Model       string     `bson:"model,omitempty" json:"model,omitempty"`
+	Attempts    int        `bson:"attempts,omitempty" json:"-"`
+	ModeratorID string     `bson:"moderator_id,omitempty" json:"moderator_id,omitempty"`
+	Note        string     `bson:"note,omitempty" json:"note,omitempty"`
+	ModeratedAt *time.Time `bson:"moderated_at,omitempty" json:"moderated_at,omitempty"`
+}
+
+// UserReview is a user's rating of a movie with an optional review. A user
+// has at most one per movie. Reviews written before moderation existed have
+// no Moderation and count as approved.
+type UserReview struct {
+	ID         bson.ObjectID     `bson:"_id,omitempty" json:"_id,omitempty"`
+	ImdbID     string            `bson:"imdb_id" json:"imdb_id"`
+	UserID     string            `bson:"user_id" json:"user_id"`
+	UserName   string            `bson:"user_name" json:"user_name"`
+	Rating     int               `bson:"rating" json:"rating" validate:"required,min=1,max=10"`
+	Review     string            `bson:"review,omitempty" json:"review,omitempty" validate:"max=5000"`
+	Moderation *ReviewModeration `bson:"moderation,omitempty" json:"moderation,omitempty"`
+	CreatedAt  time.Time         `bson:"created_at" json:"created_at"`
+	UpdatedAt  time.Time         `bson:"updated_at" json:"updated_at"`
+}
+
+// CountedRating is the rating this review contributes to the movie's
+// aggregate, 0 while it is not approved.
+func (review UserReview) CountedRating() int {
+	if review.Moderation != nil && review.Moderation.Status != ModerationApproved {
+		return 0
+	}
+
+	return review.Rating
+}
+
+// RatingAggregate summarises the user ratings of a movie. Histogram counts
+// the ratings given per value, "1" to "10". BayesianScore pulls the mean
+// towards the prior so that a handful of ratings can't top the charts.
+type RatingAggregate struct {
+	Count         int64            `bson:"count" json:"count"`
+	Sum           int64            `bson:"sum" json:"-"`
+	Mean          float64          `bson:"mean" json:"mean"`
+	BayesianScore float64          `bson:"bayesian_score" json:"bayesian_score"`
+	Histogram     map[string]int64 `bson:"histogram" json:"histogram"`
+}
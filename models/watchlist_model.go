@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+type WatchlistItem struct {
+	ImdbID  string    `bson:"imdb_id" json:"imdb_id"`
+	Note    string    `bson:"note,omitempty" json:"note,omitempty"`
+	AddedAt time.Time `bson:"added_at" json:"added_at"`
+}
+
+// Watchlist holds a user's saved movies, in the order the user chose.
+type Watchlist struct {
+	ID        bson.ObjectID   `bson:"_id,omitempty" json:"-"`
+	UserID    string          `bson:"user_id" json:"user_id"`
+	Items     []WatchlistItem `bson:"items" json:"items"`
+	UpdatedAt time.Time       `bson:"updated_at" json:"updated_at"`
+}
+
+// WatchlistEntry is a watchlist item as listed, with its movie embedded.
+// Available is false once the movie has been removed from the catalog.
+type WatchlistEntry struct {
+	Position  int       `json:"position"`
+	ImdbID    string    `json:"imdb_id"`
+	Note      string    `json:"note,omitempty"`
+	AddedAt   time.Time `json:"added_at"`
+	Available bool      `json:"available"`
+	Movie     *Movie    `json:"movie,omitempty"`
+}
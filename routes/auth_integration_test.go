@@ -0,0 +1,109 @@
+//go:build integration
+
+// Run against a disposable database:
+//
+//	MONGO_URI=mongodb://localhost:27017/ DB_NAME=moviestream_test JWT_SECRET_KEY=test JWT_REFRESH_KEY=test \
+//	  go test -tags integration ./routes/
+package routes_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	db "github.com/Neph-dev/MovieStreamServer/database"
+	"github.com/Neph-dev/MovieStreamServer/routes"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func newTestRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	routes.UnprotectedRoutes(router)
+	routes.ProtectedRoutes(router)
+
+	return router
+}
+
+func doJSON(t *testing.T, router *gin.Engine, method string, path string, token string, body any) (int, map[string]any) {
+	t.Helper()
+
+	var payload bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&payload).Encode(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	request := httptest.NewRequest(method, path, &payload)
+	request.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+
+	response := map[string]any{}
+	_ = json.Unmarshal(recorder.Body.Bytes(), &response)
+
+	return recorder.Code, response
+}
+
+// A token returned by /login must work on protected routes until logout.
+func TestLoginTokenReachesProtectedRoutes(t *testing.T) {
+	t.Setenv("UNVERIFIED_USER_ACCESS", "full")
+	t.Setenv("MAIL_PROVIDER", "outbox")
+	t.Setenv("MAIL_OUTBOX_DIR", t.TempDir())
+
+	router := newTestRouter()
+	email := "login-" + bson.NewObjectID().Hex() + "@example.com"
+
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_, _ = db.OpenCollection("users").DeleteMany(ctx, bson.M{"email": email})
+	})
+
+	status, body := doJSON(t, router, http.MethodPost, "/register", "", gin.H{
+		"first_name": "Test",
+		"last_name":  "User",
+		"email":      email,
+		"password":   "secret123",
+		"role":       "USER",
+	})
+	if status != http.StatusOK {
+		t.Fatalf("register: got %d %v", status, body)
+	}
+
+	status, body = doJSON(t, router, http.MethodPost, "/login", "", gin.H{"email": email, "password": "secret123"})
+	if status != http.StatusOK {
+		t.Fatalf("login: got %d %v", status, body)
+	}
+
+	token, _ := body["st-access-token"].(string)
+	if token == "" {
+		t.Fatalf("login: no access token in %v", body)
+	}
+
+	for range 2 {
+		status, body = doJSON(t, router, http.MethodGet, "/me", token, nil)
+		if status != http.StatusOK {
+			t.Fatalf("GET /me with a fresh login token: got %d %v", status, body)
+		}
+	}
+
+	if status, body = doJSON(t, router, http.MethodPost, "/logout", token, nil); status != http.StatusOK {
+		t.Fatalf("logout: got %d %v", status, body)
+	}
+
+	if status, body = doJSON(t, router, http.MethodGet, "/me", token, nil); status != http.StatusUnauthorized {
+		t.Fatalf("GET /me after logout: got %d %v, want 401", status, body)
+	}
+}
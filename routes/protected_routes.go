@@ -3,15 +3,81 @@ package routes
 import (
 	"github.com/Neph-dev/MovieStreamServer/controllers"
 	"github.com/Neph-dev/MovieStreamServer/middleware"
+	"github.com/Neph-dev/MovieStreamServer/models"
 	"github.com/gin-gonic/gin"
 )
 
 func ProtectedRoutes(router *gin.Engine) {
 	router.Use(middleware.AuthMiddleware())
+	// Unverified users can still manage their account and sign out.
+	router.Use(middleware.RequireVerifiedEmail("/me", "/me/email", "/me/password", "/logout", "/logout-all"))
 
-	router.PUT("/add-movie", controllers.AddMovie())
+	router.PUT("/add-movie", middleware.RequirePermission(models.PermissionMovieWrite), controllers.AddMovie())
+	router.PATCH("/movies/:imdb_id", middleware.RequirePermission(models.PermissionMovieWrite), controllers.UpdateMovie())
+	router.DELETE("/movies/:imdb_id", middleware.RequirePermission(models.PermissionMovieWrite), controllers.DeleteMovie())
 
-	router.GET("/review/:imdb_id", controllers.AdminReviewUpdate())
+	router.POST("/movies/import", middleware.RequirePermission(models.PermissionMovieWrite), controllers.ImportMovies())
+	router.GET("/movies/import/:job_id", middleware.RequirePermission(models.PermissionMovieWrite), controllers.GetImportJob())
+	router.GET("/movies/import/:job_id/rows", middleware.RequirePermission(models.PermissionMovieWrite), controllers.GetImportJobRows())
+
+	router.POST("/admin/genres", middleware.RequirePermission(models.PermissionGenreWrite), controllers.CreateGenre())
+	router.PATCH("/admin/genres/:genre_id", middleware.RequirePermission(models.PermissionGenreWrite), controllers.RenameGenre())
+	router.DELETE("/admin/genres/:genre_id", middleware.RequirePermission(models.PermissionGenreWrite), controllers.DeleteGenre())
+
+	router.GET("/admin/rankings", middleware.RequirePermission(models.PermissionRankingWrite), controllers.ListRankings())
+	router.POST("/admin/rankings", middleware.RequirePermission(models.PermissionRankingWrite), controllers.CreateRanking())
+	router.PATCH("/admin/rankings/:ranking_value", middleware.RequirePermission(models.PermissionRankingWrite), controllers.UpdateRanking())
+	router.DELETE("/admin/rankings/:ranking_value", middleware.RequirePermission(models.PermissionRankingWrite), controllers.DeleteRanking())
+
+	router.GET("/admin/prompts", middleware.RequirePermission(models.PermissionRankingWrite), controllers.ListPromptTemplates())
+	router.POST("/admin/prompts", middleware.RequirePermission(models.PermissionRankingWrite), controllers.CreatePromptTemplate())
+	router.POST("/admin/prompts/:version/activate", middleware.RequirePermission(models.PermissionRankingWrite), controllers.ActivatePromptTemplate())
+
+	router.POST("/admin/rerank", middleware.RequirePermission(models.PermissionRankingWrite), controllers.StartRerank())
+	router.GET("/admin/rerank/:job_id", middleware.RequirePermission(models.PermissionRankingWrite), controllers.GetRerankJob())
+	router.GET("/admin/rerank/:job_id/changes", middleware.RequirePermission(models.PermissionRankingWrite), controllers.GetRerankChanges())
+	router.POST("/admin/rerank/:job_id/resume", middleware.RequirePermission(models.PermissionRankingWrite), controllers.ResumeRerankJob())
+
+	router.GET("/review/:imdb_id", middleware.RequirePermission(models.PermissionReviewWrite), controllers.AdminReviewUpdate())
+	router.GET("/movies/:imdb_id/reviews/history", middleware.RequirePermission(models.PermissionReviewWrite), controllers.GetReviewHistory())
+	router.POST("/movies/:imdb_id/reviews/history/:history_id/revert", middleware.RequirePermission(models.PermissionReviewWrite), controllers.RevertReview())
+	router.GET("/review-jobs/:id", middleware.RequirePermission(models.PermissionReviewWrite), controllers.GetReviewJob())
 	router.GET("/movie/:imdb_id", controllers.GetMovieByImdbID())
+	router.PUT("/movies/:imdb_id/reviews", controllers.SaveUserReview())
+	router.DELETE("/movies/:imdb_id/reviews", controllers.DeleteUserReview())
+	router.GET("/me", controllers.GetProfile())
+	router.PATCH("/me", controllers.UpdateProfile())
+	router.POST("/me/email", controllers.RequestEmailChange())
+	router.POST("/me/password", controllers.ChangePassword())
+	router.GET("/me/reviews", controllers.GetMyReviews())
+
+	router.GET("/watchlist", controllers.GetWatchlist())
+	router.POST("/watchlist", controllers.AddToWatchlist())
+	router.PATCH("/watchlist/:imdb_id", controllers.UpdateWatchlistItem())
+	router.DELETE("/watchlist/:imdb_id", controllers.RemoveFromWatchlist())
+
+	router.POST("/playback/events", controllers.PostPlaybackEvents())
+	router.GET("/me/watch-history", controllers.GetWatchHistory())
+	router.GET("/me/continue-watching", controllers.GetContinueWatching())
+
+	router.GET("/moderation/reviews", middleware.RequirePermission(models.PermissionReviewModerate), controllers.ListModerationQueue())
+	router.POST("/moderation/reviews/:review_id/approve", middleware.RequirePermission(models.PermissionReviewModerate), controllers.ApproveUserReview())
+	router.POST("/moderation/reviews/:review_id/reject", middleware.RequirePermission(models.PermissionReviewModerate), controllers.RejectUserReview())
 	router.GET("/recommended-movies", controllers.GetRecommendedMovies())
+
+	router.DELETE("/admin/users/:user_id", middleware.RequirePermission(models.PermissionUserAdmin), controllers.DeleteUser())
+	router.PATCH("/admin/users/:user_id/role", middleware.RequirePermission(models.PermissionUserAdmin), controllers.UpdateUserRole())
+
+	router.GET("/admin/trash/:kind", middleware.RequirePermission(models.PermissionUserAdmin), controllers.ListTrash())
+	router.POST("/admin/trash/:kind/:id/restore", middleware.RequirePermission(models.PermissionUserAdmin), controllers.RestoreTrashItem())
+	router.DELETE("/admin/trash/:kind/:id", middleware.RequirePermission(models.PermissionUserAdmin), controllers.PurgeTrashItem())
+	router.POST("/admin/trash/purge", middleware.RequirePermission(models.PermissionUserAdmin), controllers.PurgeExpiredTrash())
+
+	router.GET("/admin/llm-usage", middleware.RequirePermission(models.PermissionUserAdmin), controllers.GetLLMUsage())
+	router.DELETE("/admin/llm-cache", middleware.RequirePermission(models.PermissionUserAdmin), controllers.InvalidateLLMCache())
+
+	router.GET("/admin/export/:kind", middleware.RequirePermission(models.PermissionUserAdmin), controllers.ExportData())
+
+	router.POST("/logout", controllers.LogoutUser())
+	router.POST("/logout-all", controllers.LogoutAllSessions())
 }
\ No newline at end of file
@@ -7,8 +7,17 @@ import (
 
 func UnprotectedRoutes(router *gin.Engine) {
 	router.GET("/movies", controllers.GetMovies())
+	router.GET("/movies/search", controllers.SearchMovies())
+	router.GET("/movies/:imdb_id/reviews", controllers.GetMovieUserReviews())
+	router.GET("/genres", controllers.GetGenres())
 	
 	router.POST("/register", controllers.RegisterUser())
 	router.POST("/login", controllers.LoginUser())
+	router.POST("/refresh", controllers.RefreshToken())
+	router.POST("/email/confirm", controllers.ConfirmEmailChange())
+	router.POST("/password/forgot", controllers.ForgotPassword())
+	router.POST("/password/reset", controllers.ResetPassword())
+	router.POST("/email/verify", controllers.VerifyEmail())
+	router.POST("/email/verification/resend", controllers.ResendVerificationEmail())
 
 }
\ No newline at end of file
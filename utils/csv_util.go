@@ -0,0 +1,89 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/Neph-dev/MovieStreamServer/models"
+)
+
+// MovieCSVHeader is the column layout used by both the CSV import and export.
+// The genre column holds the JSON array of genres so that ids and names
+// round-trip exactly.
+var MovieCSVHeader = []string{"imdb_id", "title", "poster_path", "youtube_id", "genre", "admin_review", "ranking_value", "ranking_name"}
+
+// MovieExportCSVHeader adds the columns an export carries for reference but
+// an import does not read.
+var MovieExportCSVHeader = append(append([]string{}, MovieCSVHeader...), "ranking_status")
+
+// CSVColumnIndex maps header names to their position and checks that every
+// column of MovieCSVHeader is present.
+func CSVColumnIndex(header []string) (map[string]int, error) {
+	index := map[string]int{}
+	for i, column := range header {
+		index[strings.TrimSpace(strings.TrimPrefix(column, "\ufeff"))] = i
+	}
+
+	for _, column := range MovieCSVHeader {
+		if _, ok := index[column]; !ok {
+			return nil, errors.New("missing CSV column " + column)
+		}
+	}
+
+	return index, nil
+}
+
+func MovieToCSVRecord(movie models.Movie) ([]string, error) {
+	genre, err := json.Marshal(movie.Genre)
+	if err != nil {
+		return nil, err
+	}
+
+	return []string{
+		movie.ImdbID,
+		movie.Title,
+		movie.PosterPath,
+		movie.YoutubeID,
+		string(genre),
+		movie.AdminReview,
+		strconv.Itoa(movie.Ranking.RankingValue),
+		movie.Ranking.RankingName,
+		movie.RankingStatus,
+	}, nil
+}
+
+func MovieFromCSVRecord(index map[string]int, record []string) (models.Movie, error) {
+	var movie models.Movie
+
+	field := func(column string) string {
+		if i := index[column]; i < len(record) {
+			return record[i]
+		}
+		return ""
+	}
+
+	movie.ImdbID = field("imdb_id")
+	movie.Title = field("title")
+	movie.PosterPath = field("poster_path")
+	movie.YoutubeID = field("youtube_id")
+	movie.AdminReview = field("admin_review")
+	movie.Ranking.RankingName = field("ranking_name")
+
+	if genre := field("genre"); genre != "" {
+		if err := json.Unmarshal([]byte(genre), &movie.Genre); err != nil {
+			return movie, errors.New("genre must be a JSON array of {genre_id, genre_name}")
+		}
+	}
+
+	if rankingValue := field("ranking_value"); rankingValue != "" {
+		value, err := strconv.Atoi(rankingValue)
+		if err != nil {
+			return movie, errors.New("ranking_value must be an integer")
+		}
+		movie.Ranking.RankingValue = value
+	}
+
+	return movie, nil
+}
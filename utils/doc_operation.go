@@ -29,4 +29,10 @@ func DocumentExists(ctx context.Context, collection *mongo.Collection, filter bs
 func UpdateDocument(ctx context.Context, collection *mongo.Collection, filter bson.M, update bson.M) error {
 	_, err := collection.UpdateOne(ctx, filter, update)
 	return err
-}
\ No newline at end of file
+}
+
+// ActiveFilter restricts filter to documents that have not been soft-deleted.
+func ActiveFilter(filter bson.M) bson.M {
+	filter["deleted_at"] = nil
+	return filter
+}
@@ -0,0 +1,29 @@
+package utils
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+func VersionETag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// IfMatchVersion reads the If-Match header. It reports present=false when the
+// header is missing and ok=false when it is not a version ETag we issued.
+func IfMatchVersion(_context *gin.Context) (version int64, present bool, ok bool) {
+	header := strings.TrimSpace(_context.GetHeader("If-Match"))
+	if header == "" {
+		return 0, false, false
+	}
+
+	header = strings.TrimPrefix(header, "W/")
+	version, err := strconv.ParseInt(strings.Trim(header, `"`), 10, 64)
+	if err != nil {
+		return 0, true, false
+	}
+
+	return version, true, true
+}
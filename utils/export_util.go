@@ -0,0 +1,128 @@
+package utils
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+var ExportContentTypes = map[string]string{
+	"ndjson": "application/x-ndjson",
+	"csv":    "text/csv",
+	"json":   "application/json",
+}
+
+// ExportWriter streams documents one at a time in NDJSON, CSV or as a single
+// JSON array, so an export never has to hold the whole collection in memory.
+type ExportWriter struct {
+	format string
+	out    io.Writer
+	csv    *csv.Writer
+	toCSV  func(interface{}) ([]string, error)
+	count  int
+}
+
+// NewExportWriter returns a writer for format. header and toCSV are only used
+// for CSV output.
+func NewExportWriter(out io.Writer, format string, header []string, toCSV func(interface{}) ([]string, error)) (*ExportWriter, error) {
+	if _, ok := ExportContentTypes[format]; !ok {
+		return nil, errors.New("format must be ndjson, csv or json")
+	}
+
+	writer := &ExportWriter{format: format, out: out, toCSV: toCSV}
+
+	switch format {
+	case "csv":
+		writer.csv = csv.NewWriter(out)
+		if err := writer.csv.Write(header); err != nil {
+			return nil, err
+		}
+	case "json":
+		if _, err := io.WriteString(out, "["); err != nil {
+			return nil, err
+		}
+	}
+
+	return writer, nil
+}
+
+func (writer *ExportWriter) Write(document interface{}) error {
+	writer.count++
+
+	switch writer.format {
+	case "csv":
+		record, err := writer.toCSV(document)
+		if err != nil {
+			return err
+		}
+		return writer.csv.Write(record)
+	case "json":
+		if writer.count > 1 {
+			if _, err := io.WriteString(writer.out, ",\n"); err != nil {
+				return err
+			}
+		}
+		line, err := json.Marshal(document)
+		if err != nil {
+			return err
+		}
+		_, err = writer.out.Write(line)
+		return err
+	default:
+		line, err := json.Marshal(document)
+		if err != nil {
+			return err
+		}
+		_, err = writer.out.Write(append(line, '\n'))
+		return err
+	}
+}
+
+// Flush pushes buffered CSV rows to the underlying writer.
+func (writer *ExportWriter) Flush() error {
+	if writer.csv != nil {
+		writer.csv.Flush()
+		return writer.csv.Error()
+	}
+
+	return nil
+}
+
+// Fail ends an export that broke off part-way with a record saying so, since
+// a truncated file would otherwise look complete. JSON output is left without
+// its closing bracket, and the CSV record has a different number of fields,
+// so neither parses as a finished export.
+func (writer *ExportWriter) Fail(exportErr error) error {
+	switch writer.format {
+	case "csv":
+		if err := writer.csv.Write([]string{"#export_error", exportErr.Error()}); err != nil {
+			return err
+		}
+		return writer.Flush()
+	default:
+		line, err := json.Marshal(map[string]interface{}{"export_error": exportErr.Error(), "exported": writer.count})
+		if err != nil {
+			return err
+		}
+		if writer.format == "json" {
+			line = append([]byte(",\n"), line...)
+		}
+		_, err = writer.out.Write(append(line, '\n'))
+		return err
+	}
+}
+
+func (writer *ExportWriter) Close() error {
+	if writer.format == "json" {
+		if _, err := io.WriteString(writer.out, "]\n"); err != nil {
+			return err
+		}
+	}
+
+	return writer.Flush()
+}
+
+func (writer *ExportWriter) Count() int {
+	return writer.count
+}
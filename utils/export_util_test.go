@@ -0,0 +1,40 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestExportWriterFail(t *testing.T) {
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"ndjson", "{\"a\":1}\n{\"export_error\":\"cursor died\",\"exported\":1}\n"},
+		{"json", "[{\"a\":1},\n{\"export_error\":\"cursor died\",\"exported\":1}\n"},
+		{"csv", "a\n1\n#export_error,cursor died\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.format, func(t *testing.T) {
+			var out bytes.Buffer
+			toCSV := func(interface{}) ([]string, error) { return []string{"1"}, nil }
+
+			writer, err := NewExportWriter(&out, test.format, []string{"a"}, toCSV)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := writer.Write(map[string]int{"a": 1}); err != nil {
+				t.Fatal(err)
+			}
+			if err := writer.Fail(errors.New("cursor died")); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := out.String(); got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
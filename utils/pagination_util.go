@@ -0,0 +1,106 @@
+package utils
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PageCursor is the keyset position after the last item of a page. It is handed
+// to clients as an opaque base64 token.
+type PageCursor struct {
+	Sort  string      `json:"s"`
+	Order int         `json:"o"`
+	Value interface{} `json:"v,omitempty"`
+	ID    string      `json:"id"`
+}
+
+type Pagination struct {
+	Limit      int64  `json:"limit"`
+	HasMore    bool   `json:"has_more"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	Page       int64  `json:"page,omitempty"`
+	TotalPages int64  `json:"total_pages,omitempty"`
+	Total      *int64 `json:"total,omitempty"`
+}
+
+func EncodeCursor(cursor PageCursor) (string, error) {
+	raw, err := json.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+func DecodeCursor(token string) (PageCursor, error) {
+	var cursor PageCursor
+
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return cursor, errors.New("malformed cursor")
+	}
+
+	if err := json.Unmarshal(raw, &cursor); err != nil {
+		return cursor, errors.New("malformed cursor")
+	}
+
+	return cursor, nil
+}
+
+func GetLimitParam(_context *gin.Context, defaultLimit int64, maxLimit int64) (int64, error) {
+	value := _context.Query("limit")
+	if value == "" {
+		return defaultLimit, nil
+	}
+
+	limit, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || limit < 1 {
+		return 0, errors.New("limit must be a positive integer")
+	}
+
+	if limit > maxLimit {
+		limit = maxLimit
+	}
+
+	return limit, nil
+}
+
+// PageLink rebuilds the current request URL with the given query parameters
+// replaced. An empty value removes the parameter.
+func PageLink(_context *gin.Context, params map[string]string) string {
+	link := url.URL{Path: _context.Request.URL.Path}
+	query := _context.Request.URL.Query()
+
+	for key, value := range params {
+		if value == "" {
+			query.Del(key)
+		} else {
+			query.Set(key, value)
+		}
+	}
+
+	link.RawQuery = query.Encode()
+
+	return link.String()
+}
+
+// SetLinkHeader writes an RFC 8288 Link header, e.g. rel="next" and rel="prev".
+func SetLinkHeader(_context *gin.Context, links map[string]string) {
+	var parts []string
+
+	for _, rel := range []string{"first", "prev", "next", "last"} {
+		if href, ok := links[rel]; ok {
+			parts = append(parts, "<"+href+`>; rel="`+rel+`"`)
+		}
+	}
+
+	if len(parts) > 0 {
+		_context.Header("Link", strings.Join(parts, ", "))
+	}
+}
@@ -0,0 +1,44 @@
+package utils
+
+import (
+	"encoding/base64"
+	"testing"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	cursor := PageCursor{Sort: "title", Order: -1, Value: "Heat", ID: "64b7f0c2a1e4c9d3f2a1b0c9"}
+
+	token, err := EncodeCursor(cursor)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := DecodeCursor(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != cursor {
+		t.Errorf("DecodeCursor(EncodeCursor(%+v)) = %+v", cursor, got)
+	}
+}
+
+func TestDecodeCursorMalformed(t *testing.T) {
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"not base64", "not a cursor!"},
+		{"padded base64", base64.URLEncoding.EncodeToString([]byte(`{"s":"title","o":1,"id":"x"}`))},
+		{"base64 of non-JSON", base64.RawURLEncoding.EncodeToString([]byte("title:1:x"))},
+		{"wrong field types", base64.RawURLEncoding.EncodeToString([]byte(`{"s":1,"o":"asc"}`))},
+		{"truncated", base64.RawURLEncoding.EncodeToString([]byte(`{"s":"title","o":1`))},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := DecodeCursor(test.token); err == nil {
+				t.Errorf("DecodeCursor(%q) accepted a malformed cursor", test.token)
+			}
+		})
+	}
+}
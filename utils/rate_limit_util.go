@@ -0,0 +1,59 @@
+package utils
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	db "github.com/Neph-dev/MovieStreamServer/database"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+var rateLimitCollection = db.OpenCollection("rate_limits")
+
+var rateLimitIndexesOnce sync.Once
+
+func ensureRateLimitIndexes(ctx context.Context) {
+	rateLimitIndexesOnce.Do(func() {
+		_, err := rateLimitCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+			{Keys: bson.D{{Key: "key", Value: 1}}, Options: options.Index().SetUnique(true)},
+			{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+		})
+		if err != nil {
+			log.Println("Warning: could not create rate limit indexes:", err)
+		}
+	})
+}
+
+// AllowRate counts a hit against key in a fixed window and reports whether it
+// is within limit. Counters live in Mongo so every instance shares them.
+func AllowRate(ctx context.Context, key string, limit int, window time.Duration) (bool, error) {
+	ensureRateLimitIndexes(ctx)
+
+	windowStart := time.Now().Truncate(window)
+	windowKey := key + "@" + strconv.FormatInt(windowStart.Unix(), 10)
+
+	var counter struct {
+		Count int `bson:"count"`
+	}
+
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	update := bson.M{
+		"$inc":         bson.M{"count": 1},
+		"$setOnInsert": bson.M{"expires_at": windowStart.Add(window)},
+	}
+
+	err := rateLimitCollection.FindOneAndUpdate(ctx, bson.M{"key": windowKey}, update, opts).Decode(&counter)
+	if mongo.IsDuplicateKeyError(err) {
+		err = rateLimitCollection.FindOneAndUpdate(ctx, bson.M{"key": windowKey}, update, opts).Decode(&counter)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return counter.Count <= limit, nil
+}
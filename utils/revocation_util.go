@@ -0,0 +1,228 @@
+package utils
+
+import (
+	"context"
+	"log"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	db "github.com/Neph-dev/MovieStreamServer/database"
+	"github.com/Neph-dev/MovieStreamServer/models"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+var revokedTokenCollection = db.OpenCollection("revoked_tokens")
+
+var revocationIndexesOnce sync.Once
+
+type revocationCacheEntry struct {
+	revoked bool
+	userID  string
+	until   time.Time
+}
+
+// revocationCache sits in front of the revoked_tokens collection so that the
+// middleware does not hit Mongo on every request. Negative answers are only
+// kept for a short while so that revocations made by other instances are
+// picked up quickly.
+type revocationCache struct {
+	mu      sync.RWMutex
+	entries map[string]revocationCacheEntry
+	ttl     time.Duration
+}
+
+var tokenRevocationCache = &revocationCache{
+	entries: map[string]revocationCacheEntry{},
+	ttl:     revocationCacheTTL(),
+}
+
+func revocationCacheTTL() time.Duration {
+	ttl := 30 * time.Second
+
+	if value := os.Getenv("REVOCATION_CACHE_TTL_SECONDS"); value != "" {
+		if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+			ttl = time.Duration(seconds) * time.Second
+		}
+	}
+
+	return ttl
+}
+
+func (cache *revocationCache) get(tokenID string) (bool, bool) {
+	cache.mu.RLock()
+	defer cache.mu.RUnlock()
+
+	entry, ok := cache.entries[tokenID]
+	if !ok || time.Now().After(entry.until) {
+		return false, false
+	}
+
+	return entry.revoked, true
+}
+
+func (cache *revocationCache) set(tokenID string, userID string, revoked bool, until time.Time) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if len(cache.entries) > 10000 {
+		now := time.Now()
+		for key, entry := range cache.entries {
+			if now.After(entry.until) {
+				delete(cache.entries, key)
+			}
+		}
+	}
+
+	cache.entries[tokenID] = revocationCacheEntry{revoked: revoked, userID: userID, until: until}
+}
+
+func (cache *revocationCache) revokeUser(userID string) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	for key, entry := range cache.entries {
+		if entry.userID == userID {
+			entry.revoked = true
+			cache.entries[key] = entry
+		}
+	}
+}
+
+func ensureRevocationIndexes(ctx context.Context) {
+	revocationIndexesOnce.Do(func() {
+		_, err := revokedTokenCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+			{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+			{Keys: bson.D{{Key: "token_id", Value: 1}}},
+			{Keys: bson.D{{Key: "kind", Value: 1}, {Key: "user_id", Value: 1}}},
+		})
+		if err != nil {
+			log.Println("Warning: could not create revoked_tokens indexes:", err)
+		}
+	})
+}
+
+// RevokeToken blacklists a single access token until it would have expired anyway.
+func RevokeToken(tokenID string, UID string, expiresAt time.Time) error {
+	if tokenID == "" {
+		return nil
+	}
+
+	var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	ensureRevocationIndexes(ctx)
+
+	_, err := revokedTokenCollection.UpdateOne(
+		ctx,
+		bson.M{"kind": models.RevocationKindToken, "token_id": tokenID},
+		bson.M{"$set": bson.M{"user_id": UID, "expires_at": expiresAt}, "$setOnInsert": bson.M{"created_at": time.Now()}},
+		options.UpdateOne().SetUpsert(true),
+	)
+	if err != nil {
+		return err
+	}
+
+	tokenRevocationCache.set(tokenID, UID, true, expiresAt)
+
+	return nil
+}
+
+// RevokeAllUserTokens rejects every access token issued to the user up to now
+// and clears the stored token pair so no refresh token can mint new ones. The
+// cutoff keeps the full issue-time precision of the tokens, so one issued
+// earlier in the same second does not slip through.
+func RevokeAllUserTokens(UID string) error {
+	var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	ensureRevocationIndexes(ctx)
+
+	now := time.Now()
+
+	_, err := revokedTokenCollection.UpdateOne(
+		ctx,
+		bson.M{"kind": models.RevocationKindUser, "user_id": UID},
+		bson.M{
+			"$set":         bson.M{"revoked_before": now, "expires_at": now.Add(AccessTokenTTL), "allowed_token_ids": bson.A{}},
+			"$setOnInsert": bson.M{"created_at": now},
+		},
+		options.UpdateOne().SetUpsert(true),
+	)
+	if err != nil {
+		return err
+	}
+
+	tokenRevocationCache.revokeUser(UID)
+
+	return RevokeTokenFamily(UID)
+}
+
+// AllowTokenAfterRevocation exempts a token issued right after
+// RevokeAllUserTokens from its cutoff, which it may share a millisecond with.
+func AllowTokenAfterRevocation(UID string, tokenID string) error {
+	var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	_, err := revokedTokenCollection.UpdateOne(
+		ctx,
+		bson.M{"kind": models.RevocationKindUser, "user_id": UID},
+		bson.M{"$addToSet": bson.M{"allowed_token_ids": tokenID}},
+	)
+	return err
+}
+
+func IsTokenRevoked(claims *SignedDetails) (bool, error) {
+	// Tokens minted before jti was introduced cannot be revoked individually.
+	if claims.ID == "" {
+		return true, nil
+	}
+
+	if revoked, ok := tokenRevocationCache.get(claims.ID); ok {
+		return revoked, nil
+	}
+
+	var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := revokedTokenCollection.Find(ctx, bson.M{"$or": bson.A{
+		bson.M{"kind": models.RevocationKindToken, "token_id": claims.ID},
+		bson.M{"kind": models.RevocationKindUser, "user_id": claims.UID},
+	}})
+	if err != nil {
+		return false, err
+	}
+	defer cursor.Close(ctx)
+
+	var records []models.RevokedToken
+	if err = cursor.All(ctx, &records); err != nil {
+		return false, err
+	}
+
+	revoked := false
+
+	for _, record := range records {
+		switch record.Kind {
+		case models.RevocationKindToken:
+			revoked = true
+		case models.RevocationKindUser:
+			issuedBefore := claims.IssuedAt == nil || !claims.IssuedAt.Time.After(record.RevokedBefore)
+			if issuedBefore && !slices.Contains(record.AllowedTokenIDs, claims.ID) {
+				revoked = true
+			}
+		}
+	}
+
+	until := time.Now().Add(tokenRevocationCache.ttl)
+	if revoked && claims.ExpiresAt != nil {
+		until = claims.ExpiresAt.Time
+	}
+
+	tokenRevocationCache.set(claims.ID, claims.UID, revoked, until)
+
+	return revoked, nil
+}
@@ -0,0 +1,190 @@
+package utils
+
+import (
+	"html"
+	"strings"
+	"unicode"
+)
+
+// NormalizeText lowercases s and collapses everything that is not a letter or
+// digit into single spaces.
+func NormalizeText(s string) string {
+	var builder strings.Builder
+	lastSpace := true
+
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			builder.WriteRune(r)
+			lastSpace = false
+		} else if !lastSpace {
+			builder.WriteRune(' ')
+			lastSpace = true
+		}
+	}
+
+	return strings.TrimSpace(builder.String())
+}
+
+func SearchTerms(query string) []string {
+	return strings.Fields(NormalizeText(query))
+}
+
+// Trigrams returns the set of padded character trigrams of every word in s,
+// in the same way pg_trgm does.
+func Trigrams(s string) map[string]struct{} {
+	grams := map[string]struct{}{}
+
+	for _, word := range strings.Fields(NormalizeText(s)) {
+		runes := []rune("  " + word + " ")
+		for i := 0; i+3 <= len(runes); i++ {
+			grams[string(runes[i:i+3])] = struct{}{}
+		}
+	}
+
+	return grams
+}
+
+// TrigramSimilarity is the Jaccard index of the trigram sets of a and b.
+func TrigramSimilarity(a string, b string) float64 {
+	gramsA := Trigrams(a)
+	gramsB := Trigrams(b)
+
+	if len(gramsA) == 0 || len(gramsB) == 0 {
+		return 0
+	}
+
+	shared := 0
+	for gram := range gramsA {
+		if _, ok := gramsB[gram]; ok {
+			shared++
+		}
+	}
+
+	return float64(shared) / float64(len(gramsA)+len(gramsB)-shared)
+}
+
+func Levenshtein(a string, b string) int {
+	runesA := []rune(a)
+	runesB := []rune(b)
+
+	previous := make([]int, len(runesB)+1)
+	current := make([]int, len(runesB)+1)
+
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(runesA); i++ {
+		current[0] = i
+		for j := 1; j <= len(runesB); j++ {
+			cost := 1
+			if runesA[i-1] == runesB[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+
+	return previous[len(runesB)]
+}
+
+// MaxTypos is how many edits a word of the given length may be away from a
+// search term and still count as a match.
+func MaxTypos(word string) int {
+	switch length := len([]rune(word)); {
+	case length <= 3:
+		return 0
+	case length <= 6:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// FuzzyWordMatches returns the words of text that are within MaxTypos edits of
+// one of the terms.
+func FuzzyWordMatches(text string, terms []string) []string {
+	var matches []string
+
+	for _, word := range strings.Fields(NormalizeText(text)) {
+		for _, term := range terms {
+			if Levenshtein(word, term) <= MaxTypos(term) {
+				matches = append(matches, word)
+				break
+			}
+		}
+	}
+
+	return matches
+}
+
+// Highlight wraps every occurrence of the words in <em></em> and, when the
+// text is longer than maxLength runes, cuts a window around the first match.
+// The text itself is HTML-escaped, so the result is safe to render as markup.
+// It returns an empty string when none of the words occur in text.
+func Highlight(text string, words []string, maxLength int) string {
+	runes := []rune(text)
+	lower := []rune(strings.ToLower(text))
+	marked := make([]bool, len(runes))
+	found := false
+
+	for _, word := range words {
+		wordRunes := []rune(strings.ToLower(word))
+		if len(wordRunes) == 0 {
+			continue
+		}
+
+		for i := 0; i+len(wordRunes) <= len(lower); i++ {
+			if string(lower[i:i+len(wordRunes)]) != string(wordRunes) {
+				continue
+			}
+			if i > 0 && isWordRune(lower[i-1]) {
+				continue
+			}
+			for k := i; k < i+len(wordRunes); k++ {
+				marked[k] = true
+			}
+			found = true
+		}
+	}
+
+	if !found {
+		return ""
+	}
+
+	start, end := 0, len(runes)
+	if maxLength > 0 && len(runes) > maxLength {
+		first := 0
+		for first < len(marked) && !marked[first] {
+			first++
+		}
+		start = max(0, first-maxLength/3)
+		end = min(len(runes), start+maxLength)
+	}
+
+	var builder strings.Builder
+	if start > 0 {
+		builder.WriteString("…")
+	}
+
+	for i := start; i < end; i++ {
+		if marked[i] && (i == start || !marked[i-1]) {
+			builder.WriteString("<em>")
+		}
+		builder.WriteString(html.EscapeString(string(runes[i])))
+		if marked[i] && (i == end-1 || !marked[i+1]) {
+			builder.WriteString("</em>")
+		}
+	}
+
+	if end < len(runes) {
+		builder.WriteString("…")
+	}
+
+	return builder.String()
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
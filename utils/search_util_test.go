@@ -0,0 +1,49 @@
+package utils
+
+import "testing"
+
+func TestHighlight(t *testing.T) {
+	tests := []struct {
+		name      string
+		text      string
+		words     []string
+		maxLength int
+		want      string
+	}{
+		{"marks every occurrence", "Heat and more heat", []string{"heat"}, 0, "<em>Heat</em> and more <em>heat</em>"},
+		{"only at word starts", "reheated heat", []string{"heat"}, 0, "reheated <em>heat</em>"},
+		{"no match", "Heat", []string{"cold"}, 0, ""},
+		{"escapes markup", "<script>x</script>", []string{"script"}, 0, "&lt;<em>script</em>&gt;x&lt;/<em>script</em>&gt;"},
+		{"escapes around matches", `"Tom & Jerry"`, []string{"jerry"}, 0, "&#34;Tom &amp; <em>Jerry</em>&#34;"},
+		{"cuts a window", "one two three four five six", []string{"four"}, 12, "…ree <em>four</em> fiv…"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := Highlight(test.text, test.words, test.maxLength); got != test.want {
+				t.Errorf("Highlight(%q, %q, %d) = %q, want %q", test.text, test.words, test.maxLength, got, test.want)
+			}
+		})
+	}
+}
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"good", "", 4},
+		{"good", "good", 0},
+		{"good", "gold", 1},
+		{"terible", "terrible", 1},
+		{"okay", "ok", 2},
+		{"café", "cafe", 1},
+	}
+
+	for _, test := range tests {
+		if got := Levenshtein(test.a, test.b); got != test.want {
+			t.Errorf("Levenshtein(%q, %q) = %d, want %d", test.a, test.b, got, test.want)
+		}
+	}
+}
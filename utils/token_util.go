@@ -10,6 +10,8 @@ import (
 	"github.com/gin-gonic/gin"
 	jwt "github.com/golang-jwt/jwt/v5"
 	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
 )
 
 type SignedDetails struct {
@@ -18,24 +20,67 @@ type SignedDetails struct {
 	LastName  string
 	UID      string
 	Role     string
+	Family   string
+	EmailVerified bool
 	jwt.RegisteredClaims
 }
 
+const AccessTokenTTL = time.Hour * 24
+const RefreshTokenTTL = time.Hour * 168 // 7 days
+
 var JWT_SECRET_KEY = os.Getenv("JWT_SECRET_KEY")
 var JWT_REFRESH_KEY = os.Getenv("JWT_REFRESH_KEY")
 var userCollection = db.OpenCollection("users")
 
-func GenerateAllTokens(email string, firstName string, lastName string, UID string, role string) (signedToken string, signedRefreshToken string, err error) {
+// Issue times are kept to the millisecond, so that a revocation cutoff only
+// catches the tokens issued before it.
+func init() {
+	jwt.TimePrecision = time.Millisecond
+}
+
+// TokenID returns the jti of a token signed by SignAllTokens, or "" when it
+// cannot be read.
+func TokenID(signedToken string) string {
+	claims := &SignedDetails{}
+	if _, _, err := jwt.NewParser().ParseUnverified(signedToken, claims); err != nil {
+		return ""
+	}
+
+	return claims.ID
+}
+
+func NewTokenFamily() string {
+	return bson.NewObjectID().Hex()
+}
+
+func GenerateAllTokens(email string, firstName string, lastName string, UID string, role string, family string, emailVerified bool) (signedToken string, signedRefreshToken string, err error) {
+	token, refreshToken, err := SignAllTokens(email, firstName, lastName, UID, role, family, emailVerified)
+	if err != nil {
+		return "", "", err
+	}
+
+	err = UpdateTokens(token, refreshToken, UID, family)
+	if err != nil {
+		return "", "", err
+	}
+	
+	return token, refreshToken, nil
+}
+
+func SignAllTokens(email string, firstName string, lastName string, UID string, role string, family string, emailVerified bool) (signedToken string, signedRefreshToken string, err error) {
 	claims := &SignedDetails{
 		Email:     email,
 		FirstName: firstName,
 		LastName:  lastName,
 		UID:       UID,
 		Role:      role,
+		Family:    family,
+		EmailVerified: emailVerified,
 		RegisteredClaims: jwt.RegisteredClaims{
 			Issuer: "MovieStream",
+			ID: bson.NewObjectID().Hex(),
 			IssuedAt: jwt.NewNumericDate(time.Now()),
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour * 24)),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(AccessTokenTTL)),
 		},
 	}
 
@@ -45,9 +90,13 @@ func GenerateAllTokens(email string, firstName string, lastName string, UID stri
 		LastName:  lastName,
 		UID:       UID,
 		Role:      role,
+		Family:    family,
+		EmailVerified: emailVerified,
 		RegisteredClaims: jwt.RegisteredClaims{
 			Issuer: "MovieStream",
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour * 168)), // 7 days
+			ID: bson.NewObjectID().Hex(),
+			IssuedAt: jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(RefreshTokenTTL)),
 		},
 	}
 
@@ -61,24 +110,113 @@ func GenerateAllTokens(email string, firstName string, lastName string, UID stri
 		return "", "", err
 	}
 
-	err = UpdateTokens(token, refreshToken, UID)
-	if err != nil {
-		return "", "", err
-	}
-	
 	return token, refreshToken, nil
 }
 
-func UpdateTokens(signedToken string, signedRefreshToken string, UID string) error {
+func UpdateTokens(signedToken string, signedRefreshToken string, UID string, family string) error {
 	var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
 	defer cancel()
 
-	err := UpdateDocument(
+	err := replaceStoredTokens(
 		ctx,
-		userCollection,
 		bson.M{"user_id": UID},
+		bson.M{"$set": bson.M{"token": signedToken, "refresh_token": signedRefreshToken, "token_family": family, "updated_at": time.Now()}},
+		signedToken,
+	)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil
+	}
+
+	return err
+}
+
+// replaceStoredTokens overwrites the user's stored tokens and revokes the
+// access token that was stored before, so an overwritten token stops working.
+// Storing the same access token again revokes nothing. It returns
+// mongo.ErrNoDocuments when the filter matched nothing.
+func replaceStoredTokens(ctx context.Context, filter bson.M, update bson.M, newToken string) error {
+	var previous struct {
+		UserID string `bson:"user_id"`
+		Token  string `bson:"token"`
+	}
+
+	opts := options.FindOneAndUpdate().
+		SetProjection(bson.M{"user_id": 1, "token": 1}).
+		SetReturnDocument(options.Before)
+
+	err := userCollection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&previous)
+	if err != nil {
+		return err
+	}
+
+	if previous.Token == "" || previous.Token == newToken {
+		return nil
+	}
+
+	claims := &SignedDetails{}
+	if _, _, err := jwt.NewParser().ParseUnverified(previous.Token, claims); err != nil || claims.ExpiresAt == nil {
+		return nil
+	}
+
+	return RevokeToken(claims.ID, previous.UserID, claims.ExpiresAt.Time)
+}
+
+// RotateTokens swaps in a new token pair only if presentedRefreshToken is still
+// the stored one, so two concurrent refreshes with the same token cannot both win.
+func RotateTokens(presentedRefreshToken string, signedToken string, signedRefreshToken string, UID string) (bool, error) {
+	var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	err := replaceStoredTokens(
+		ctx,
+		bson.M{"user_id": UID, "refresh_token": presentedRefreshToken},
 		bson.M{"$set": bson.M{"token": signedToken, "refresh_token": signedRefreshToken, "updated_at": time.Now()}},
+		signedToken,
+	)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// RevokeTokenFamily clears every token stored for the user so that neither the
+// current access token nor any refresh token of the family can be used again.
+func RevokeTokenFamily(UID string) error {
+	var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	err := replaceStoredTokens(
+		ctx,
+		bson.M{"user_id": UID},
+		bson.M{"$set": bson.M{"token": "", "refresh_token": "", "token_family": "", "updated_at": time.Now()}},
+		"",
 	)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil
+	}
+
+	return err
+}
+
+// EndSession clears the stored token pair only if it still belongs to the given
+// family, so logging out a stale session does not end a newer one.
+func EndSession(UID string, family string) error {
+	var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+	defer cancel()
+
+	err := replaceStoredTokens(
+		ctx,
+		bson.M{"user_id": UID, "token_family": family},
+		bson.M{"$set": bson.M{"token": "", "refresh_token": "", "token_family": "", "updated_at": time.Now()}},
+		"",
+	)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil
+	}
 
 	return err
 }
@@ -116,6 +254,24 @@ func ValidateToken(signedToken string) (*SignedDetails, error) {
 	return claims, nil
 }
 
+func ValidateRefreshToken(signedToken string) (*SignedDetails, error) {
+	claims := &SignedDetails{}
+
+	token, err := jwt.ParseWithClaims(signedToken, claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(JWT_REFRESH_KEY), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !token.Valid {
+		return nil, errors.New("invalid refresh token")
+	}
+
+	return claims, nil
+}
+
 func GetDataFromContext(_context *gin.Context, field string) (string, error) {
 	value, exists := _context.Get(field)
 	if !exists {